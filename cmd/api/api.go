@@ -7,29 +7,109 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/Amir-Zouerami/EWG-simple-API-server/docs" // required for swagger
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/auth"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/metrics"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/ratelimit"
 	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store/cache"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/tracing"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 type application struct {
-	config config
-	store  store.Storage
-	logger *zap.SugaredLogger
+	config        config
+	store         store.Storage
+	logger        *zap.SugaredLogger
+	authenticator auth.Authenticator
+	cursorSigner  *store.CursorSigner
+	rateLimiters  rateLimiters
+	cache         cache.Storage
+	metrics       *metrics.Metrics
+	healthChecks  []healthCheck
+	shuttingDown  atomic.Bool
+}
+
+// rateLimiters are the limiters of each route group. ip runs before
+// authentication, so that requests with a missing or forged token are counted
+// too, and api after it, per user.
+type rateLimiters struct {
+	auth ratelimit.Limiter
+	ip   ratelimit.Limiter
+	api  ratelimit.Limiter
 }
 
 type config struct {
+	addr        string
+	apiURL      string
+	env         string
+	version     string
+	db          dbConfig
+	auth        authConfig
+	feed        feedConfig
+	rateLimiter rateLimiterConfig
+	cache       cacheConfig
+	redis       redisConfig
+	metrics     metricsConfig
+	tracing     tracingConfig
+	log         logConfig
+	trash       trashConfig
+
+	// shutdownDelay is how long the server keeps serving, while reporting
+	// not-ready, before it starts draining on SIGTERM. It gives load
+	// balancers time to stop routing new requests to it.
+	shutdownDelay time.Duration
+}
+
+type logConfig struct {
+	// level overrides the default level of the APP_MODE, debug in
+	// development and info otherwise.
+	level            string
+	healthSampleRate int
+}
+
+// trashConfig sets how long deleted posts and comments can be restored for
+// and how often the purger removes those past it.
+type trashConfig struct {
+	retention     time.Duration
+	purgeEnabled  bool
+	purgeInterval time.Duration
+}
+
+type tracingConfig struct {
+	exporter string
+	endpoint string
+	file     string
+}
+
+func (c tracingConfig) enabled() bool {
+	return c.exporter != "" && c.exporter != "none"
+}
+
+// metricsConfig enables the Prometheus metrics, which are served on their
+// own listener at addr rather than next to the public API.
+type metricsConfig struct {
+	enabled bool
 	addr    string
-	apiURL  string
-	env     string
-	version string
-	db      dbConfig
+}
+
+type redisConfig struct {
+	addr string
+}
+
+type cacheConfig struct {
+	enabled bool
+	backend string
+	size    int
+	ttl     time.Duration
 }
 
 type dbConfig struct {
@@ -37,6 +117,39 @@ type dbConfig struct {
 	maxOpenConns int
 	maxIdleConns int
 	maxIdleTime  string
+	autoMigrate  bool
+}
+
+type feedConfig struct {
+	cursorSecret string
+}
+
+// rateLimiterConfig holds a strict fixed window for the authentication routes,
+// which are the usual target of credential stuffing, and looser token
+// buckets for the rest of the API, per client IP and per user.
+type rateLimiterConfig struct {
+	enabled      bool
+	backend      string
+	authRequests int
+	authWindow   time.Duration
+	ipRate       int
+	ipBurst      int
+	apiRate      int
+	apiBurst     int
+}
+
+type authConfig struct {
+	invitationExp time.Duration
+	token         tokenConfig
+}
+
+type tokenConfig struct {
+	alg        string
+	secret     string
+	privateKey string
+	publicKey  string
+	exp        time.Duration
+	iss        string
 }
 
 func (app *application) mount() http.Handler {
@@ -44,48 +157,133 @@ func (app *application) mount() http.Handler {
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+
+	if app.config.tracing.enabled() {
+		r.Use(tracing.Middleware)
+	}
+
+	r.Use(app.accessLog)
+
+	// Before Recoverer, so that panics are counted as the 500s they become.
+	if app.metrics != nil {
+		r.Use(app.metrics.Middleware)
+	}
+
 	r.Use(middleware.Recoverer)
 
 	r.Use(middleware.Timeout(60 * time.Second))
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
+		r.Get("/health/live", app.livenessHandler)
+		r.Get("/health/ready", app.readinessHandler)
 
 		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
 		r.Route("/posts", func(r chi.Router) {
+			r.Use(app.rateLimit(app.rateLimiters.ip))
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.rateLimit(app.rateLimiters.api))
+
 			r.Post("/", app.createPostHandler)
+			r.Post("/{postID}/restore", app.restorePostHandler)
 
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postContextMiddleware)
 
 				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.deletePostHandler)
-				r.Patch("/", app.updatePostHandler)
+				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
+				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+
+				r.Put("/reactions/{type}", app.addPostReactionHandler)
+				r.Delete("/reactions/{type}", app.removePostReactionHandler)
+
+				r.Route("/comments", func(r chi.Router) {
+					r.Get("/", app.getCommentsHandler)
+					r.Post("/", app.createCommentHandler)
+					r.Post("/{commentID}/restore", app.restoreCommentHandler)
+
+					r.Route("/{commentID}", func(r chi.Router) {
+						r.Use(app.commentContextMiddleware)
+
+						r.Patch("/", app.checkCommentOwnership("moderator", app.updateCommentHandler))
+						r.Delete("/", app.checkCommentOwnership("admin", app.deleteCommentHandler))
+
+						r.Put("/reactions/{type}", app.addCommentReactionHandler)
+						r.Delete("/reactions/{type}", app.removeCommentReactionHandler)
+					})
+				})
 			})
 		})
 
 		r.Route("/users", func(r chi.Router) {
+			r.With(app.rateLimit(app.rateLimiters.auth)).Put("/activate/{token}", app.activateUserHandler)
+
 			r.Route("/{userID}", func(r chi.Router) {
+				r.Use(app.rateLimit(app.rateLimiters.ip))
+				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.rateLimit(app.rateLimiters.api))
 				r.Use(app.userContextMIddleware)
 
 				r.Get("/", app.getUserHandler)
+				r.Patch("/", app.updateUserHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Delete("/block", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Delete("/mute", app.unmuteUserHandler)
+				r.Get("/followers", app.followListHandler(store.FollowersList))
+				r.Get("/following", app.followListHandler(store.FollowingList))
+				r.Get("/mutuals", app.followListHandler(store.MutualsList))
 			})
 
 			r.Group(func(r chi.Router) {
+				r.Use(app.rateLimit(app.rateLimiters.ip))
+				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.rateLimit(app.rateLimiters.api))
 				r.Get("/feed", app.getUserFeedHandler)
+				r.Get("/follow-requests", app.followRequestsHandler)
+				r.Put("/follow-requests/{userID}", app.approveFollowRequestHandler)
+				r.Delete("/follow-requests/{userID}", app.rejectFollowRequestHandler)
 			})
 		})
 
+		r.With(app.rateLimit(app.rateLimiters.ip), app.AuthTokenMiddleware, app.rateLimit(app.rateLimiters.api)).Get("/search", app.searchHandler)
+
+		r.Route("/me", func(r chi.Router) {
+			r.Use(app.rateLimit(app.rateLimiters.ip))
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.rateLimit(app.rateLimiters.api))
+
+			r.Get("/trash", app.trashHandler)
+		})
+
+		r.Route("/authentication", func(r chi.Router) {
+			r.Use(app.rateLimit(app.rateLimiters.auth))
+
+			r.Post("/user", app.registerUserHandler)
+			r.Post("/token", app.createTokenHandler)
+		})
+
 	})
 
 	return r
 }
 
+// ctxLogger returns the application logger annotated with the trace and span
+// of ctx, so that log lines can be matched with traces.
+func (app *application) ctxLogger(ctx context.Context) *zap.SugaredLogger {
+	sc := trace.SpanContextFromContext(ctx)
+
+	if !sc.IsValid() {
+		return app.logger
+	}
+
+	return app.logger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+}
+
 func (app *application) run(mux http.Handler) error {
 	// Docs
 	docs.SwaggerInfo.Version = "0.0.1"
@@ -100,18 +298,39 @@ func (app *application) run(mux http.Handler) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	var metricsSrv *http.Server
+
+	if app.metrics != nil {
+		metricsSrv = app.serveMetrics()
+	}
+
 	shutdown := make(chan error)
 
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+
+	if app.config.trash.purgeEnabled {
+		go app.purgeTrash(purgeCtx)
+	}
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		s := <-quit
 
+		app.logger.Infow("OS signal caught", "signal", s.String())
+
+		// Readiness has to fail before the listener closes.
+		app.shuttingDown.Store(true)
+		time.Sleep(app.config.shutdownDelay)
+
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 		defer cancel()
 
-		app.logger.Infow("OS signal caught", "signal", s.String())
+		if metricsSrv != nil {
+			_ = metricsSrv.Shutdown(ctx)
+		}
 
 		shutdown <- srv.Shutdown(ctx)
 	}()
@@ -132,3 +351,28 @@ func (app *application) run(mux http.Handler) error {
 	app.logger.Infow("server has stopped", "addr", app.config.addr, "env", app.config.env)
 	return nil
 }
+
+// serveMetrics starts the metrics listener in the background. Failing to
+// bind it is logged rather than fatal, so metrics never take the API down.
+func (app *application) serveMetrics() *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", app.metrics.Handler())
+
+	srv := &http.Server{
+		Addr:         app.config.metrics.addr,
+		Handler:      mux,
+		WriteTimeout: time.Second * 30,
+		ReadTimeout:  time.Second * 10,
+		IdleTimeout:  time.Minute,
+	}
+
+	go func() {
+		app.logger.Infow("Metrics server started", "addr", srv.Addr)
+
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			app.logger.Errorw("Metrics server failed", "addr", srv.Addr, "error", err.Error())
+		}
+	}()
+
+	return srv
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/auth"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store/memstore"
+	"go.uber.org/zap"
+)
+
+// newTestApp builds the application on the in-memory storage, with rate
+// limiting, caching, metrics and tracing left out. It runs in development
+// mode so that registration hands back the activation token.
+func newTestApp(t *testing.T) *application {
+	t.Helper()
+
+	authenticator, err := auth.NewJWTAuthenticator("test-secret", "test", "test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &application{
+		config: config{
+			env: "development",
+			auth: authConfig{
+				invitationExp: time.Hour,
+				token:         tokenConfig{exp: time.Hour, iss: "test"},
+			},
+			trash: trashConfig{retention: time.Hour},
+		},
+		store:         memstore.New(),
+		logger:        zap.NewNop().Sugar(),
+		authenticator: authenticator,
+		cursorSigner:  store.NewCursorSigner("test-secret"),
+	}
+}
+
+// testClient sends requests to a mounted application as one user.
+type testClient struct {
+	t     *testing.T
+	h     http.Handler
+	token string
+	id    int64
+}
+
+type testResponse struct {
+	code   int
+	header http.Header
+	body   []byte
+}
+
+// decode unmarshals the data of the response envelope into v.
+func (r testResponse) decode(t *testing.T, v any) {
+	t.Helper()
+
+	envelope := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+
+	if err := json.Unmarshal(r.body, &envelope); err != nil {
+		t.Fatalf("decoding %s: %v", r.body, err)
+	}
+
+	if err := json.Unmarshal(envelope.Data, v); err != nil {
+		t.Fatalf("decoding %s: %v", envelope.Data, err)
+	}
+}
+
+func (c *testClient) do(method, path string, body any, header ...string) testResponse {
+	c.t.Helper()
+
+	var payload bytes.Buffer
+
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &payload)
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	rr := httptest.NewRecorder()
+	c.h.ServeHTTP(rr, req)
+
+	return testResponse{code: rr.Code, header: rr.Header(), body: rr.Body.Bytes()}
+}
+
+// expect sends a request and fails the test unless it is answered with
+// code.
+func (c *testClient) expect(code int, method, path string, body any, header ...string) testResponse {
+	c.t.Helper()
+
+	res := c.do(method, path, body, header...)
+
+	if res.code != code {
+		c.t.Fatalf("%s %s: got %d, want %d: %s", method, path, res.code, code, res.body)
+	}
+
+	return res
+}
+
+// registerUser signs up, activates and logs in a user named username.
+func registerUser(t *testing.T, h http.Handler, username string) *testClient {
+	t.Helper()
+
+	c := &testClient{t: t, h: h}
+	email := username + "@example.com"
+
+	var user UserWithToken
+
+	c.expect(http.StatusCreated, http.MethodPost, "/v1/authentication/user", map[string]string{
+		"username": username,
+		"email":    email,
+		"password": "password",
+	}).decode(t, &user)
+
+	c.id = user.ID
+	c.activateAndLogIn(user.Token, email)
+
+	return c
+}
+
+// registerUserWithRole creates a user with role straight in the store, since
+// the API only signs up plain users, and logs them in.
+func registerUserWithRole(t *testing.T, app *application, h http.Handler, username, role string) *testClient {
+	t.Helper()
+
+	c := &testClient{t: t, h: h}
+	email := username + "@example.com"
+	token := username + "-activation"
+
+	user := &store.User{Username: username, Email: email, Role: store.Role{Name: role}}
+
+	if err := user.Password.Set("password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.store.Users.CreateAndInvite(context.Background(), user, token, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	c.id = user.ID
+	c.activateAndLogIn(token, email)
+
+	return c
+}
+
+func (c *testClient) activateAndLogIn(token, email string) {
+	c.t.Helper()
+
+	c.expect(http.StatusNoContent, http.MethodPut, "/v1/users/activate/"+token, nil)
+
+	c.expect(http.StatusCreated, http.MethodPost, "/v1/authentication/token", map[string]string{
+		"email":    email,
+		"password": "password",
+	}).decode(c.t, &c.token)
+}
+
+func (c *testClient) createPost(title string) *store.Post {
+	c.t.Helper()
+
+	var post store.Post
+
+	c.expect(http.StatusOK, http.MethodPost, "/v1/posts", map[string]string{
+		"title":   title,
+		"content": "content of " + title,
+	}).decode(c.t, &post)
+
+	return &post
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCreateTokenRefusesBadCredentials(t *testing.T) {
+	h := newTestApp(t).mount()
+	registerUser(t, h, "alice")
+
+	c := &testClient{t: t, h: h}
+
+	tests := []struct {
+		name, email, password string
+	}{
+		{"unknown email", "nobody@example.com", "password"},
+		{"wrong password", "alice@example.com", "not the password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.expect(http.StatusUnauthorized, http.MethodPost, "/v1/authentication/token", map[string]string{
+				"email":    tt.email,
+				"password": tt.password,
+			})
+		})
+	}
+}
+
+// An unknown email only takes as long to refuse as a wrong password while
+// dummyHash is a well-formed hash at the cost real passwords are hashed at.
+func TestDummyHashCost(t *testing.T) {
+	cost, err := bcrypt.Cost(dummyHash)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("got cost %d, want %d", cost, bcrypt.DefaultCost)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateCommentPayload struct {
+	Content  string `json:"content" validate:"required,max=1000"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gte=1"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+type commentContextKey string
+
+const commentKey commentContextKey = "comment"
+
+func (app *application) getCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	format := r.URL.Query().Get("format")
+
+	if format == "" {
+		format = "flat"
+	}
+
+	if format != "flat" && format != "tree" {
+		app.badRequestError(w, r, clientError("format must be one of: flat tree"))
+		return
+	}
+
+	user := getAuthUserFromContext(r)
+
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, user.ID)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.attachCommentReactions(r.Context(), *comments, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var data any = *comments
+
+	if format == "tree" {
+		data = store.BuildCommentTree(*comments)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, data); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getAuthUserFromContext(r)
+
+	var payload CreateCommentPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if payload.ParentID != nil {
+		parent, err := app.store.Comments.GetByID(ctx, *payload.ParentID)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestError(w, r, clientError("parent comment does not exist"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if parent.PostID != post.ID {
+			app.badRequestError(w, r, clientError("parent comment belongs to another post"))
+			return
+		}
+
+		blocked, err := app.blockedBetween(ctx, user.ID, parent.UserID)
+
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		// Like in the comment listing, a blocked user's comments don't
+		// exist for the viewer.
+		if blocked {
+			app.badRequestError(w, r, clientError("parent comment does not exist"))
+			return
+		}
+	}
+
+	comment := &store.Comment{
+		PostID:   post.ID,
+		UserID:   user.ID,
+		ParentID: payload.ParentID,
+		Content:  payload.Content,
+		User: store.User{
+			ID:       user.ID,
+			Username: user.Username,
+		},
+	}
+
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	var payload UpdateCommentPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	comment.Content = payload.Content
+
+	if err := app.store.Comments.UpdateByID(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteCommentHandler moves the comment and its replies to their authors'
+// trash.
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+	user := getAuthUserFromContext(r)
+
+	if err := app.store.Comments.DeleteByID(r.Context(), comment.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restoreCommentHandler takes a comment out of the trash, for its author or
+// whoever deleted it, with the replies deleted along with it.
+func (app *application) restoreCommentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getAuthUserFromContext(r)
+	ctx := r.Context()
+
+	if err := app.store.Comments.Restore(ctx, post.ID, id, user.ID, app.config.trash.retention); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	comment, err := app.store.Comments.GetByID(ctx, id)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) commentContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		comment, err := app.store.Comments.GetByID(ctx, id)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if comment.PostID != getPostFromCtx(r).ID {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
+		blocked, err := app.blockedBetween(ctx, getAuthUserFromContext(r).ID, comment.UserID)
+
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if blocked {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentKey, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentKey).(*store.Comment)
+	return comment
+}
@@ -1,29 +1,198 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/go-playground/validator/v10"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("Internal server error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.ctxLogger(r.Context()).Errorw("Internal server error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
-	writeJSONError(w, http.StatusInternalServerError, "the server encountered a problem")
+	writeJSONError(w, r, http.StatusInternalServerError, "the server encountered a problem")
 }
 
+// badRequestError turns validation failures into per-field errors and
+// rewrites JSON decoding errors, whose raw messages mention Go types, into
+// something a client can act on.
 func (app *application) badRequestError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("Bad request", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.ctxLogger(r.Context()).Warnw("Bad request", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	var validationErrs validator.ValidationErrors
+
+	if errors.As(err, &validationErrs) {
+		writeProblem(w, r, problem{
+			Type:   "urn:ewgsocial:problem:validation",
+			Title:  "Validation failed",
+			Status: http.StatusBadRequest,
+			Detail: "one or more fields are invalid",
+			Errors: fieldErrors(validationErrs),
+		})
+		return
+	}
+
+	writeJSONError(w, r, http.StatusBadRequest, badRequestDetail(err))
+}
+
+// clientError is a bad request message written for the client, which
+// badRequestError passes on as is. Anything else it does not recognize is
+// answered with a fixed message, since it may carry internal details.
+type clientError string
+
+func (e clientError) Error() string {
+	return string(e)
+}
+
+func badRequestDetail(err error) string {
+	var (
+		syntaxErr    *json.SyntaxError
+		typeErr      *json.UnmarshalTypeError
+		maxBytesErr  *http.MaxBytesError
+		numErr       *strconv.NumError
+		paramErr     *store.ParamError
+		clientErr    clientError
+		unknownField = "json: unknown field "
+	)
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("body contains badly-formed JSON at character %d", syntaxErr.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "body contains badly-formed JSON"
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return fmt.Sprintf("body field %q must be a %s", typeErr.Field, jsonKind(typeErr.Type.Kind()))
+		}
+
+		return fmt.Sprintf("body must be a %s", jsonKind(typeErr.Type.Kind()))
+	case errors.Is(err, io.EOF):
+		return "body must not be empty"
+	case strings.HasPrefix(err.Error(), unknownField):
+		return fmt.Sprintf("body contains unknown field %s", strings.TrimPrefix(err.Error(), unknownField))
+	case errors.As(err, &maxBytesErr):
+		return fmt.Sprintf("body must not be larger than %d bytes", maxBytesErr.Limit)
+	case errors.As(err, &numErr):
+		return fmt.Sprintf("%q is not a valid number", numErr.Num)
+	case errors.As(err, &paramErr):
+		return fmt.Sprintf("invalid %s: expected %s", paramErr.Param, paramErr.Expected)
+	case errors.Is(err, store.ErrInvalidCursor):
+		return "invalid pagination cursor"
+	case errors.Is(err, store.ErrDuplicateEmail):
+		return "a user with that email already exists"
+	case errors.Is(err, store.ErrDuplicateUsername):
+		return "a user with that username already exists"
+	case errors.As(err, &clientErr):
+		return clientErr.Error()
+	default:
+		return "the request is invalid"
+	}
+}
+
+func jsonKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "number"
+	}
+}
+
+func fieldErrors(validationErrs validator.ValidationErrors) []fieldError {
+	fields := make([]fieldError, 0, len(validationErrs))
+
+	for _, fe := range validationErrs {
+		fields = append(fields, fieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldErrorMessage(fe),
+		})
+	}
+
+	return fields
+}
 
-	writeJSONError(w, http.StatusBadRequest, err.Error())
+func fieldErrorMessage(fe validator.FieldError) string {
+	unit := "characters"
+
+	if kind := fe.Kind(); kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map {
+		unit = "items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return fe.Field() + " is required"
+	case "max":
+		return fmt.Sprintf("%s must be at most %s %s long", fe.Field(), fe.Param(), unit)
+	case "min":
+		return fmt.Sprintf("%s must be at least %s %s long", fe.Field(), fe.Param(), unit)
+	case "email":
+		return fe.Field() + " must be a valid email address"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", fe.Field(), fe.Param())
+	default:
+		return fmt.Sprintf("%s failed the %s check", fe.Field(), fe.Tag())
+	}
 }
 
 func (app *application) notFoundError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("Resource not found", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.ctxLogger(r.Context()).Errorw("Resource not found", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
-	writeJSONError(w, http.StatusNotFound, "not found")
+	writeJSONError(w, r, http.StatusNotFound, "not found")
 }
 
 func (app *application) conflictError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("Conflict error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	app.ctxLogger(r.Context()).Errorw("Conflict error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, r, http.StatusConflict, "resource already exists")
+}
+
+func (app *application) unauthorizedError(w http.ResponseWriter, r *http.Request, err error) {
+	app.ctxLogger(r.Context()).Warnw("Unauthorized error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	w.Header().Set("WWW-Authenticate", `Bearer charset="UTF-8"`)
+	writeJSONError(w, r, http.StatusUnauthorized, "unauthorized")
+}
+
+func (app *application) forbiddenError(w http.ResponseWriter, r *http.Request) {
+	app.ctxLogger(r.Context()).Warnw("Forbidden", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, r, http.StatusForbidden, "forbidden")
+}
+
+func (app *application) preconditionFailedError(w http.ResponseWriter, r *http.Request, err error) {
+	app.ctxLogger(r.Context()).Warnw("Precondition failed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, r, http.StatusPreconditionFailed, "the resource has been modified, fetch it again and retry")
+}
+
+func (app *application) preconditionRequiredError(w http.ResponseWriter, r *http.Request) {
+	app.ctxLogger(r.Context()).Warnw("Precondition required", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, r, http.StatusPreconditionRequired, "the If-Match header is required")
+}
+
+func (app *application) rateLimitExceededError(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.ctxLogger(r.Context()).Warnw("Rate limit exceeded", "method", r.Method, "path", r.URL.Path)
+
+	// Retry-After is in whole seconds; round up so clients don't retry early.
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
 
-	writeJSONError(w, http.StatusConflict, "resource already exists")
+	writeJSONError(w, r, http.StatusTooManyRequests, "rate limit exceeded, retry after "+strconv.Itoa(seconds)+"s")
 }
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
 )
@@ -24,14 +29,77 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	posts, err := app.store.Posts.GetUserFeed(r.Context(), int64(1), fq)
+	user := getAuthUserFromContext(r)
+
+	if fq.Cursor != "" {
+		position, err := app.decodeCursor(fq.Cursor, feedCursorScope(user.ID, fq))
+
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		fq.Position = position
+		fq.Sort = position.Sort
+		fq.Offset = 0
+	}
+
+	posts, err := app.store.Posts.GetUserFeed(r.Context(), user.ID, fq)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	next, prev, err := app.feedCursors(user.ID, fq, posts)
 
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+	if err := app.paginatedJSONResponse(w, http.StatusOK, posts, next, prev); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+func (app *application) feedCursors(userID int64, fq store.FeedPaginationQuery, posts []*store.FeedRecord) (next, prev string, err error) {
+	scope := feedCursorScope(userID, fq)
+
+	return app.pageCursors(fq.Position, fq.Offset, fq.Limit, len(posts), func(i int) (store.Cursor, error) {
+		createdAt, err := time.Parse(time.RFC3339Nano, posts[i].CreatedAt)
+
+		if err != nil {
+			return store.Cursor{}, err
+		}
+
+		return store.Cursor{
+			CreatedAt: createdAt,
+			ID:        posts[i].ID,
+			Sort:      fq.Sort,
+			Scope:     scope,
+		}, nil
+	})
+}
+
+// feedCursorScope ties feed cursors to the user and the filters they were
+// issued under, so that replaying one under other filters is refused rather
+// than paging through a different result set. The filters are hashed to keep
+// cursors short.
+func feedCursorScope(userID int64, fq store.FeedPaginationQuery) string {
+	tags := slices.Clone(fq.Tags)
+	slices.Sort(tags)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%q\x00%q", userID, tags, fq.Search)
+
+	for _, t := range []*time.Time{fq.Since, fq.Until} {
+		if t != nil {
+			fmt.Fprint(h, t.UTC().Format(time.RFC3339Nano))
+		}
+
+		h.Write([]byte{0})
+	}
+
+	return "feed:" + hex.EncodeToString(h.Sum(nil)[:12])
+}
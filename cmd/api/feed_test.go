@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+func TestFeedOrder(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+	bob := registerUser(t, h, "bobby")
+
+	bob.expect(http.StatusNoContent, http.MethodPut, fmt.Sprintf("/v1/users/%d/follow", alice.id), nil)
+
+	var want []int64
+
+	for i := range 3 {
+		want = append(want, alice.createPost(fmt.Sprintf("post %d", i)).ID)
+	}
+
+	feedIDs := func(path string) []int64 {
+		var records []*store.FeedRecord
+		bob.expect(http.StatusOK, http.MethodGet, path, nil).decode(t, &records)
+
+		ids := make([]int64, len(records))
+
+		for i, record := range records {
+			ids[i] = record.ID
+		}
+
+		return ids
+	}
+
+	if got := feedIDs("/v1/users/feed?sort=asc"); !slices.Equal(got, want) {
+		t.Errorf("ascending feed: got %v, want %v", got, want)
+	}
+
+	slices.Reverse(want)
+
+	if got := feedIDs("/v1/users/feed"); !slices.Equal(got, want) {
+		t.Errorf("default feed: got %v, want %v", got, want)
+	}
+}
+
+func TestFeedRejectsMalformedParams(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+
+	var problem struct {
+		Detail string `json:"detail"`
+	}
+
+	res := alice.expect(http.StatusBadRequest, http.MethodGet, "/v1/users/feed?limit=ten", nil)
+
+	if err := json.Unmarshal(res.body, &problem); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "invalid limit: expected an integer"; problem.Detail != want {
+		t.Errorf("got detail %q, want %q", problem.Detail, want)
+	}
+}
+
+// feedPage is one page of the feed along with its cursors.
+type feedPage struct {
+	ids        []int64
+	next, prev string
+}
+
+func (c *testClient) feedPage(path string) feedPage {
+	c.t.Helper()
+
+	res := c.expect(http.StatusOK, http.MethodGet, path, nil)
+
+	var records []*store.FeedRecord
+	res.decode(c.t, &records)
+
+	var cursors struct {
+		Next string `json:"next_cursor"`
+		Prev string `json:"prev_cursor"`
+	}
+
+	if err := json.Unmarshal(res.body, &cursors); err != nil {
+		c.t.Fatal(err)
+	}
+
+	page := feedPage{next: cursors.Next, prev: cursors.Prev}
+
+	for _, record := range records {
+		page.ids = append(page.ids, record.ID)
+	}
+
+	return page
+}
+
+func TestFeedCursorsAcrossEqualTimestamps(t *testing.T) {
+	app := newTestApp(t)
+	h := app.mount()
+	alice := registerUser(t, h, "alice")
+	bob := registerUser(t, h, "bobby")
+
+	bob.expect(http.StatusNoContent, http.MethodPut, fmt.Sprintf("/v1/users/%d/follow", alice.id), nil)
+
+	// Three of the five posts share a timestamp, so that with two posts per
+	// page the first page boundary falls between posts created at the same
+	// time and only the id can order them.
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var posts []*store.Post
+
+	for i, offset := range []time.Duration{0, time.Hour, time.Hour, time.Hour, 2 * time.Hour} {
+		posts = append(posts, &store.Post{
+			Title:     fmt.Sprintf("post %d", i),
+			Content:   "content",
+			UserID:    alice.id,
+			CreatedAt: base.Add(offset).Format(time.RFC3339),
+		})
+	}
+
+	if err := app.store.Posts.CreateBatch(context.Background(), posts); err != nil {
+		t.Fatal(err)
+	}
+
+	var want []int64
+
+	for _, post := range posts {
+		want = append(want, post.ID)
+	}
+
+	for _, sort := range []string{"asc", "desc"} {
+		t.Run(sort, func(t *testing.T) {
+			want := slices.Clone(want)
+
+			if sort == "desc" {
+				slices.Reverse(want)
+			}
+
+			var pages []feedPage
+			page := bob.feedPage("/v1/users/feed?limit=2&sort=" + sort)
+
+			for {
+				pages = append(pages, page)
+
+				if page.next == "" {
+					break
+				}
+
+				if len(pages) > len(want) {
+					t.Fatal("the feed does not end")
+				}
+
+				page = bob.feedPage("/v1/users/feed?limit=2&cursor=" + page.next)
+			}
+
+			var got []int64
+
+			for _, page := range pages {
+				got = append(got, page.ids...)
+			}
+
+			if !slices.Equal(got, want) {
+				t.Fatalf("paging forwards: got %v, want %v", got, want)
+			}
+
+			for i := len(pages) - 1; i > 0; i-- {
+				if pages[i].prev == "" {
+					t.Fatalf("page %d has no previous cursor", i)
+				}
+
+				prev := bob.feedPage("/v1/users/feed?limit=2&cursor=" + pages[i].prev)
+
+				if !slices.Equal(prev.ids, pages[i-1].ids) {
+					t.Errorf("paging back from page %d: got %v, want %v", i, prev.ids, pages[i-1].ids)
+				}
+			}
+		})
+	}
+}
+
+func TestFeedRejectsCursorsFromAnotherScope(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+	bob := registerUser(t, h, "bobby")
+
+	bob.expect(http.StatusNoContent, http.MethodPut, fmt.Sprintf("/v1/users/%d/follow", alice.id), nil)
+
+	for i := range 3 {
+		alice.createPost(fmt.Sprintf("post %d", i))
+	}
+
+	cursor := bob.feedPage("/v1/users/feed?limit=2").next
+
+	if cursor == "" {
+		t.Fatal("the first page has no next cursor")
+	}
+
+	bob.expect(http.StatusOK, http.MethodGet, "/v1/users/feed?limit=2&cursor="+cursor, nil)
+
+	tests := []struct {
+		name   string
+		client *testClient
+		path   string
+	}{
+		{"other filters", bob, "/v1/users/feed?limit=2&tags=go&cursor=" + cursor},
+		{"other search", bob, "/v1/users/feed?limit=2&search=post&cursor=" + cursor},
+		{"other user", alice, "/v1/users/feed?limit=2&cursor=" + cursor},
+		{"tampered", bob, "/v1/users/feed?limit=2&cursor=x" + cursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.client.expect(http.StatusBadRequest, http.MethodGet, tt.path, nil)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// followListHandler serves one of the follow lists of the user in the URL.
+func (app *application) followListHandler(list string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app.serveFollowList(w, r, list, getUserFromContext(r))
+	}
+}
+
+// followRequestsHandler lists the pending follow requests of the
+// authenticated user.
+func (app *application) followRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	app.serveFollowList(w, r, store.FollowRequestsList, getAuthUserFromContext(r))
+}
+
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.Approve)
+}
+
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.store.Followers.Reject)
+}
+
+func (app *application) answerFollowRequest(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, targetID, requesterID int64) error) {
+	requesterID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	currUser := getAuthUserFromContext(r)
+	ctx := r.Context()
+
+	if err := fn(ctx, currUser.ID, requesterID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUsers(ctx, currUser.ID, requesterID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveFollowList writes a page of one of user's follow lists, newest first.
+func (app *application) serveFollowList(w http.ResponseWriter, r *http.Request, list string, user *store.User) {
+	fq := store.FollowListQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	// Scoped to the user too, so a cursor can't be replayed against
+	// somebody else's list.
+	scope := list + ":" + strconv.FormatInt(user.ID, 10)
+
+	if fq.Cursor != "" {
+		position, err := app.decodeCursor(fq.Cursor, scope)
+
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		fq.Position = position
+		fq.Offset = 0
+	}
+
+	entries, err := app.store.Followers.List(r.Context(), list, user.ID, getAuthUserFromContext(r).ID, fq)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	next, prev, err := app.pageCursors(fq.Position, fq.Offset, fq.Limit, len(entries), func(i int) (store.Cursor, error) {
+		since, err := time.Parse(time.RFC3339Nano, entries[i].Since)
+
+		if err != nil {
+			return store.Cursor{}, err
+		}
+
+		return store.Cursor{
+			CreatedAt: since,
+			ID:        entries[i].ID,
+			Sort:      "desc",
+			Scope:     scope,
+		}, nil
+	})
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, entries, next, prev); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -3,7 +3,11 @@ package main
 import (
 	"encoding/json"
 	"net/http"
+	"reflect"
+	"strings"
 
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/tracing"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -11,6 +15,17 @@ var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON names, which is what clients know them as.
+	Validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -28,12 +43,80 @@ func readJSON[T any](w http.ResponseWriter, r *http.Request, data *T) error {
 	return decoder.Decode(data)
 }
 
-func writeJSONError(w http.ResponseWriter, status int, message string) error {
-	type envelope struct {
-		Error string `json:"error"`
+// problem is an RFC 7807 problem details object. Type is a URI identifying
+// the kind of problem; "about:blank" means the status code says it all.
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, message string) error {
+	return writeProblem(w, r, problem{Status: status, Detail: message})
+}
+
+// writeProblem answers with application/problem+json, filling in whatever p
+// leaves empty from the request. Clients that accept application/json but not
+// application/problem+json get the older {"error": "..."} shape instead.
+func writeProblem(w http.ResponseWriter, r *http.Request, p problem) error {
+	if p.Type == "" {
+		p.Type = "about:blank"
 	}
 
-	return writeJSON(w, status, &envelope{Error: message})
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.GetReqID(r.Context())
+	p.TraceID = tracing.TraceID(r.Context())
+
+	if !wantsProblemJSON(r) {
+		type envelope struct {
+			Error   string `json:"error"`
+			TraceID string `json:"trace_id,omitempty"`
+		}
+
+		return writeJSON(w, p.Status, &envelope{Error: p.Detail, TraceID: p.TraceID})
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	return json.NewEncoder(w).Encode(p)
+}
+
+// wantsProblemJSON is true unless the Accept header lists application/json
+// without application/problem+json. Missing and wildcard headers get problem
+// details.
+func wantsProblemJSON(r *http.Request) bool {
+	accept := r.Header.Values("Accept")
+	plainJSON := false
+
+	for _, header := range accept {
+		for _, mediaRange := range strings.Split(header, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+
+			switch strings.ToLower(strings.TrimSpace(mediaType)) {
+			case "application/problem+json":
+				return true
+			case "application/json":
+				plainJSON = true
+			}
+		}
+	}
+
+	return !plainJSON
 }
 
 func (app *application) jsonResponse(w http.ResponseWriter, status int, data any) error {
@@ -43,3 +126,13 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 
 	return writeJSON(w, status, &envelope{Data: data})
 }
+
+func (app *application) paginatedJSONResponse(w http.ResponseWriter, status int, data any, nextCursor, prevCursor string) error {
+	type envelope struct {
+		Data       any    `json:"data"`
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+	}
+
+	return writeJSON(w, status, &envelope{Data: data, NextCursor: nextCursor, PrevCursor: prevCursor})
+}
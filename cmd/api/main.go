@@ -1,9 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/cmd/migrate/migrations"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/auth"
 	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/db"
 	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/env"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/metrics"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/migrate"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/ratelimit"
 	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store/cache"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/tracing"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
@@ -35,13 +48,88 @@ func main() {
 			maxOpenConns: env.GetInt("DB_MAX_OPEN_CONNS", 30),
 			maxIdleConns: env.GetInt("DB_MAX_IDLE_CONNS", 30),
 			maxIdleTime:  env.GetString("DB_MAX_IDLE_TIME", "15m"),
+			autoMigrate:  env.GetBool("DB_AUTO_MIGRATE", false),
+		},
+		auth: authConfig{
+			invitationExp: time.Hour * 24 * 3, // 3 days
+			token: tokenConfig{
+				alg:        env.GetString("AUTH_TOKEN_ALG", "HS256"),
+				secret:     env.GetString("AUTH_TOKEN_SECRET", ""),
+				privateKey: env.GetString("AUTH_TOKEN_PRIVATE_KEY", ""),
+				publicKey:  env.GetString("AUTH_TOKEN_PUBLIC_KEY", ""),
+				exp:        time.Hour * 24 * 3, // 3 days
+				iss:        "ewgsocial",
+			},
+		},
+		feed: feedConfig{
+			cursorSecret: env.GetString("FEED_CURSOR_SECRET", ""),
+		},
+		rateLimiter: rateLimiterConfig{
+			enabled:      env.GetBool("RATELIMITER_ENABLED", true),
+			backend:      env.GetString("RATELIMITER_BACKEND", "memory"),
+			authRequests: env.GetInt("RATELIMITER_AUTH_REQUESTS", 10),
+			authWindow:   time.Minute,
+			ipRate:       env.GetInt("RATELIMITER_IP_RATE", 100),
+			ipBurst:      env.GetInt("RATELIMITER_IP_BURST", 200),
+			apiRate:      env.GetInt("RATELIMITER_API_RATE", 20),
+			apiBurst:     env.GetInt("RATELIMITER_API_BURST", 40),
+		},
+		cache: cacheConfig{
+			enabled: env.GetBool("CACHE_ENABLED", false),
+			backend: env.GetString("CACHE_BACKEND", "lru"),
+			size:    env.GetInt("CACHE_SIZE", 10_000),
+			ttl:     time.Minute,
+		},
+		redis: redisConfig{
+			addr: env.GetString("REDIS_ADDR", "localhost:6379"),
+		},
+		metrics: metricsConfig{
+			enabled: env.GetBool("METRICS_ENABLED", false),
+			addr:    env.GetString("METRICS_ADDR", "localhost:9090"),
+		},
+		log: logConfig{
+			level:            env.GetString("LOG_LEVEL", ""),
+			healthSampleRate: env.GetInt("LOG_HEALTH_SAMPLE_RATE", 100),
+		},
+		trash: trashConfig{
+			retention:     time.Duration(env.GetInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour,
+			purgeEnabled:  env.GetBool("TRASH_PURGE_ENABLED", true),
+			purgeInterval: time.Duration(env.GetInt("TRASH_PURGE_INTERVAL_MINUTES", 60)) * time.Minute,
+		},
+		shutdownDelay: time.Duration(env.GetInt("SHUTDOWN_DELAY_SECONDS", 5)) * time.Second,
+		tracing: tracingConfig{
+			exporter: env.GetString("TRACING_EXPORTER", "none"),
+			endpoint: env.GetString("TRACING_OTLP_ENDPOINT", ""),
+			file:     env.GetString("TRACING_FILE", "traces.jsonl"),
 		},
 	}
 
 	// Logger
-	logger := zap.Must(zap.NewProduction()).Sugar()
+	logger := zap.Must(newLogger(cfg.env, cfg.log.level)).Sugar()
 	defer logger.Sync()
 
+	// Tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.tracing.exporter,
+		Endpoint:    cfg.tracing.endpoint,
+		File:        cfg.tracing.file,
+		ServiceName: "ewgsocial-api",
+		Version:     cfg.version,
+	})
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorw("flushing traces failed", "error", err.Error())
+		}
+	}()
+
 	// Database
 	db, err := db.New(
 		cfg.db.addr,
@@ -57,15 +145,192 @@ func main() {
 	defer db.Close()
 	logger.Info("db connection pool established")
 
+	migrator, err := migrate.New(db, migrations.FS)
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if cfg.db.autoMigrate {
+		if err := autoMigrate(migrator); err != nil {
+			logger.Fatal(err)
+		}
+
+		logger.Info("database migrations are up to date")
+	}
+
+	if cfg.feed.cursorSecret == "" || cfg.feed.cursorSecret == placeholderSecret {
+		logger.Fatal("FEED_CURSOR_SECRET must be set to a private value")
+	}
+
+	cursorSigner := store.NewCursorSigner(cfg.feed.cursorSecret)
 	store := store.NewStorage(db)
 
+	if cfg.tracing.enabled() {
+		store = tracing.InstrumentStorage(store)
+	}
+
+	// Metrics
+	var appMetrics *metrics.Metrics
+
+	if cfg.metrics.enabled {
+		appMetrics = metrics.New(cfg.version, db)
+		store = appMetrics.InstrumentStorage(store)
+	}
+
+	// Authenticator
+	authenticator, err := newAuthenticator(cfg.auth.token)
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// Redis, only connected when a component is configured to use it
+	var rdb *redis.Client
+
+	if cfg.rateLimiter.backend == "redis" || (cfg.cache.enabled && cfg.cache.backend == "redis") {
+		rdb = redis.NewClient(&redis.Options{Addr: cfg.redis.addr})
+		defer rdb.Close()
+
+		logger.Info("redis client created")
+	}
+
+	// Rate limiter
+	rateLimiters, err := newRateLimiters(cfg.rateLimiter, rdb)
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// Cache
+	cacheStorage, err := newCache(cfg.cache, rdb)
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	app := &application{
-		config: cfg,
-		store:  store,
-		logger: logger,
+		config:        cfg,
+		store:         store,
+		logger:        logger,
+		authenticator: authenticator,
+		cursorSigner:  cursorSigner,
+		rateLimiters:  rateLimiters,
+		cache:         cacheStorage,
+		metrics:       appMetrics,
+		healthChecks:  []healthCheck{postgresHealthCheck(db, migrator)},
+	}
+
+	if rdb != nil {
+		app.healthChecks = append(app.healthChecks, redisHealthCheck(rdb))
 	}
 
 	mux := app.mount()
 
 	logger.Fatal(app.run(mux))
 }
+
+// newLogger logs human-readable lines at debug level in development and JSON
+// at info level everywhere else, unless level says otherwise.
+func newLogger(mode, level string) (*zap.Logger, error) {
+	cfg := zap.NewProductionConfig()
+
+	if mode == "development" {
+		cfg = zap.NewDevelopmentConfig()
+	}
+
+	// zap's sampler keys on the message, so it would drop most access log
+	// lines under load; accessLog samples what is safe to sample itself.
+	cfg.Sampling = nil
+
+	if level != "" {
+		atomicLevel, err := zap.ParseAtomicLevel(level)
+
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.Level = atomicLevel
+	}
+
+	return cfg.Build()
+}
+
+// placeholderSecret is what the secrets used to default to. Being public, it
+// is refused like a missing secret.
+const placeholderSecret = "example"
+
+func newAuthenticator(cfg tokenConfig) (auth.Authenticator, error) {
+	switch cfg.alg {
+	case "HS256":
+		if cfg.secret == "" || cfg.secret == placeholderSecret {
+			return nil, errors.New("AUTH_TOKEN_SECRET must be set to a private value")
+		}
+
+		return auth.NewJWTAuthenticator(cfg.secret, cfg.iss, cfg.iss)
+	case "RS256":
+		return auth.NewRSAJWTAuthenticator(cfg.privateKey, cfg.publicKey, cfg.iss, cfg.iss)
+	default:
+		return nil, fmt.Errorf("unsupported AUTH_TOKEN_ALG %q", cfg.alg)
+	}
+}
+
+func newRateLimiters(cfg rateLimiterConfig, rdb *redis.Client) (rateLimiters, error) {
+	var backend ratelimit.Store
+
+	switch cfg.backend {
+	case "memory":
+		backend = ratelimit.NewMemoryStore()
+	case "redis":
+		backend = ratelimit.NewRedisStore(rdb)
+	default:
+		return rateLimiters{}, fmt.Errorf("unsupported RATELIMITER_BACKEND %q", cfg.backend)
+	}
+
+	return rateLimiters{
+		auth: &ratelimit.FixedWindow{
+			Store:  backend,
+			Prefix: "ratelimit:auth:",
+			Limit:  cfg.authRequests,
+			Window: cfg.authWindow,
+		},
+		ip: &ratelimit.TokenBucket{
+			Store:  backend,
+			Prefix: "ratelimit:ip:",
+			Rate:   float64(cfg.ipRate),
+			Burst:  cfg.ipBurst,
+		},
+		api: &ratelimit.TokenBucket{
+			Store:  backend,
+			Prefix: "ratelimit:api:",
+			Rate:   float64(cfg.apiRate),
+			Burst:  cfg.apiBurst,
+		},
+	}, nil
+}
+
+func newCache(cfg cacheConfig, rdb *redis.Client) (cache.Storage, error) {
+	if !cfg.enabled {
+		return cache.Storage{}, nil
+	}
+
+	switch cfg.backend {
+	case "lru":
+		return cache.NewLRUStorage(cfg.size, cfg.ttl), nil
+	case "redis":
+		return cache.NewRedisStorage(rdb, cfg.ttl), nil
+	default:
+		return cache.Storage{}, fmt.Errorf("unsupported CACHE_BACKEND %q", cfg.backend)
+	}
+}
+
+func autoMigrate(m *migrate.Migrator) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, err := m.Up(ctx, 0); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/ratelimit"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+
+		if authHeader == "" {
+			app.unauthorizedError(w, r, errors.New("authorization header is missing"))
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			app.unauthorizedError(w, r, errors.New("authorization header is malformed"))
+			return
+		}
+
+		jwtToken, err := app.authenticator.ValidateToken(parts[1])
+
+		if err != nil {
+			app.unauthorizedError(w, r, err)
+			return
+		}
+
+		sub, err := jwtToken.Claims.GetSubject()
+
+		if err != nil {
+			app.unauthorizedError(w, r, err)
+			return
+		}
+
+		userID, err := strconv.ParseInt(sub, 10, 64)
+
+		if err != nil {
+			app.unauthorizedError(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		user, err := app.getUser(ctx, userID)
+
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.unauthorizedError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if !user.IsActive {
+			app.unauthorizedError(w, r, errors.New("user is not activated"))
+			return
+		}
+
+		if entry := getAccessLogEntry(r); entry != nil {
+			entry.userID = user.ID
+		}
+
+		ctx = context.WithValue(ctx, authUserCtxKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type accessLogContextKey string
+
+const accessLogKey accessLogContextKey = "accessLog"
+
+// accessLogEntry lets middlewares further down fill in what the access log
+// can only learn after routing, such as the authenticated user.
+type accessLogEntry struct {
+	userID int64
+}
+
+// sensitiveHeaders are logged as redacted rather than left out, so it is
+// still visible that the client sent them.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// sensitiveParams are route parameters that carry secrets, such as the
+// activation token.
+var sensitiveParams = []string{"token"}
+
+// accessLog replaces chi's middleware.Logger with one structured line per
+// request. Requests to the /v1/health endpoints are sampled, 1 in healthSampleRate, unless
+// they fail. Request headers are only logged at debug level.
+func (app *application) accessLog(next http.Handler) http.Handler {
+	var healthRequests atomic.Uint64
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), accessLogKey, entry)))
+
+		status := ww.Status()
+
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		route := ""
+		path := r.URL.Path
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+
+			for _, param := range sensitiveParams {
+				if value := rctx.URLParam(param); value != "" {
+					path = strings.ReplaceAll(path, value, "[REDACTED]")
+				}
+			}
+		}
+
+		if strings.HasPrefix(route, "/v1/health") && status < http.StatusBadRequest {
+			rate := uint64(max(app.config.log.healthSampleRate, 1))
+
+			if (healthRequests.Add(1)-1)%rate != 0 {
+				return
+			}
+		}
+
+		logger := app.ctxLogger(r.Context())
+
+		fields := []any{
+			"request_id", middleware.GetReqID(r.Context()),
+			"method", r.Method,
+			"path", path,
+			"route", route,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"latency", time.Since(start),
+			"remote_ip", clientIP(r),
+			"user_agent", r.UserAgent(),
+		}
+
+		if entry.userID != 0 {
+			fields = append(fields, "user_id", entry.userID)
+		}
+
+		if logger.Level().Enabled(zap.DebugLevel) {
+			fields = append(fields, "headers", redactHeaders(r.Header))
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			logger.Errorw("request", fields...)
+		case status >= http.StatusBadRequest:
+			logger.Warnw("request", fields...)
+		default:
+			logger.Infow("request", fields...)
+		}
+	})
+}
+
+func getAccessLogEntry(r *http.Request) *accessLogEntry {
+	entry, _ := r.Context().Value(accessLogKey).(*accessLogEntry)
+	return entry
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+
+	for name, values := range header {
+		if sensitiveHeaders[name] {
+			redacted[name] = "[REDACTED]"
+			continue
+		}
+
+		redacted[name] = strings.Join(values, ", ")
+	}
+
+	return redacted
+}
+
+// checkPostOwnership lets the owner of a post through and otherwise requires
+// the authenticated user to hold at least requiredRole.
+func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := getAuthUserFromContext(r)
+		post := getPostFromCtx(r)
+
+		if post.UserID == user.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenError(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// checkCommentOwnership is the comment counterpart of checkPostOwnership.
+func (app *application) checkCommentOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := getAuthUserFromContext(r)
+		comment := getCommentFromCtx(r)
+
+		if comment.UserID == user.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenError(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// rateLimit rejects requests over limiter's budget with 429. Requests are
+// counted per authenticated user when the route is authenticated, so it has to
+// come after AuthTokenMiddleware there, and per client IP otherwise.
+func (app *application) rateLimit(limiter ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !app.config.rateLimiter.enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := limiter.Allow(r.Context(), rateLimitKey(r))
+
+			// Fail open: an unreachable limiter backend shouldn't take the
+			// whole API down with it.
+			if err != nil {
+				app.ctxLogger(r.Context()).Errorw("Rate limiter error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !res.Allowed {
+				app.rateLimitExceededError(w, r, res.RetryAfter)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitKey(r *http.Request) string {
+	if user := getAuthUserFromContext(r); user != nil {
+		return "user:" + strconv.FormatInt(user.ID, 10)
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+// clientIP relies on middleware.RealIP having already replaced RemoteAddr
+// with the client address when the request came through a proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
+	role, err := app.store.Roles.GetByName(ctx, roleName)
+
+	if err != nil {
+		return false, err
+	}
+
+	return user.Role.Level >= role.Level, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/ratelimit"
+)
+
+func TestRateLimitCountsUnauthenticatedRequests(t *testing.T) {
+	app := newTestApp(t)
+	store := ratelimit.NewMemoryStore()
+
+	app.config.rateLimiter.enabled = true
+	app.rateLimiters = rateLimiters{
+		auth: &ratelimit.FixedWindow{Store: store, Prefix: "auth:", Limit: 100, Window: time.Minute},
+		ip:   &ratelimit.TokenBucket{Store: store, Prefix: "ip:", Rate: 0.01, Burst: 2},
+		api:  &ratelimit.TokenBucket{Store: store, Prefix: "api:", Rate: 100, Burst: 100},
+	}
+
+	c := &testClient{t: t, h: app.mount(), token: "forged"}
+
+	c.expect(http.StatusUnauthorized, http.MethodGet, "/v1/users/feed", nil)
+	c.expect(http.StatusUnauthorized, http.MethodGet, "/v1/posts/1", nil)
+	c.expect(http.StatusTooManyRequests, http.MethodGet, "/v1/search?q=x", nil)
+}
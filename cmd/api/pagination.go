@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+// pageCursors signs the cursors pointing past either end of a keyset page of
+// n rows. A next cursor is only handed out while pages keep coming back full,
+// and a prev cursor only when the page didn't start at the top of the list.
+// position returns the cursor of the i-th row; its Direction is filled in
+// here.
+func (app *application) pageCursors(from *store.Cursor, offset, limit, n int, position func(i int) (store.Cursor, error)) (next, prev string, err error) {
+	if n == 0 {
+		return "", "", nil
+	}
+
+	full := n == limit
+	backward := from != nil && from.Direction == store.CursorPrev
+	notFirstPage := from != nil || offset > 0
+
+	if full || backward {
+		next, err = app.signCursor(position, n-1, store.CursorNext)
+
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if notFirstPage && (full || !backward) {
+		prev, err = app.signCursor(position, 0, store.CursorPrev)
+
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return next, prev, nil
+}
+
+func (app *application) signCursor(position func(i int) (store.Cursor, error), i int, direction string) (string, error) {
+	c, err := position(i)
+
+	if err != nil {
+		return "", err
+	}
+
+	c.Direction = direction
+
+	return app.cursorSigner.Encode(c)
+}
+
+// decodeCursor verifies a client supplied cursor and checks that it was
+// issued for the list identified by scope.
+func (app *application) decodeCursor(token, scope string) (*store.Cursor, error) {
+	c, err := app.cursorSigner.Decode(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Scope != scope {
+		return nil, store.ErrInvalidCursor
+	}
+
+	return c, nil
+}
@@ -5,20 +5,23 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
 	"github.com/go-chi/chi/v5"
 )
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title      string   `json:"title" validate:"required,max=100"`
+	Content    string   `json:"content" validate:"required,max=1000"`
+	Tags       []string `json:"tags"`
+	Visibility string   `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Title      *string `json:"title" validate:"omitempty,max=100"`
+	Content    *string `json:"content" validate:"omitempty,max=1000"`
+	Visibility *string `json:"visibility" validate:"omitempty,oneof=public followers mentioned private"`
 }
 
 type postContextKey string
@@ -26,6 +29,8 @@ type postContextKey string
 const postKey postContextKey = "post"
 
 func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getAuthUserFromContext(r)
+
 	var payload CreatePostPayload
 
 	if err := readJSON(w, r, &payload); err != nil {
@@ -39,10 +44,11 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  1, //TODO: change after auth
+		Title:      payload.Title,
+		Content:    payload.Content,
+		Tags:       payload.Tags,
+		UserID:     user.ID,
+		Visibility: payload.Visibility,
 	}
 
 	if err := app.store.Posts.Create(r.Context(), post); err != nil {
@@ -57,21 +63,41 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
+	user := getAuthUserFromContext(r)
+	ctx := r.Context()
+
+	comments, err := app.store.Comments.GetByPostID(ctx, post.ID, user.ID)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	if err := app.attachCommentReactions(ctx, *comments, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 
 	post.Comments = *comments
 
+	summaries, err := app.store.Reactions.Summaries(ctx, store.PostReaction, []int64{post.ID}, user.ID)
+
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	post.Reactions = summaries[post.ID]
+
+	w.Header().Set("ETag", postETag(post))
+
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+// deletePostHandler moves the post to its author's trash, even when a
+// moderator or admin deletes it.
 func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "postID")
 	id, err := strconv.ParseInt(idParam, 10, 64)
@@ -81,7 +107,9 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := app.store.Posts.DeleteByID(r.Context(), id); err != nil {
+	user := getAuthUserFromContext(r)
+
+	if err := app.store.Posts.DeleteByID(r.Context(), id, user.ID); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundError(w, r, err)
@@ -91,13 +119,67 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	app.invalidatePost(r.Context(), id)
+
 	w.WriteHeader(http.StatusNoContent)
 
 }
 
+// restorePostHandler takes a post out of the trash, for its author or
+// whoever deleted it. It
+// sits outside postContextMiddleware, which doesn't find trashed posts.
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromContext(r)
+	ctx := r.Context()
+
+	if err := app.store.Posts.Restore(ctx, id, user.ID, app.config.trash.retention); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidatePost(ctx, id)
+
+	post, err := app.getPost(ctx, id)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", postETag(post))
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
+	ifMatch := r.Header.Get("If-Match")
+
+	if ifMatch == "" {
+		app.preconditionRequiredError(w, r)
+		return
+	}
+
+	if !etagMatches(ifMatch, postETag(post)) {
+		app.preconditionFailedError(w, r, store.ErrVersionConflict)
+		return
+	}
+
 	var payload UpdatePostPayload
 
 	if err := readJSON(w, r, &payload); err != nil {
@@ -118,11 +200,30 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Title = *payload.Title
 	}
 
-	if err := app.store.Posts.UpdateByID(r.Context(), post); err != nil {
-		app.internalServerError(w, r, err)
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
+
+	err := app.store.Posts.UpdateByID(r.Context(), post)
+
+	// Drop the cached post on conflicts too, or the stale entry would keep
+	// failing the version check.
+	app.invalidatePost(r.Context(), post.ID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrVersionConflict):
+			app.preconditionFailedError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	w.Header().Set("ETag", postETag(post))
+
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -141,7 +242,7 @@ func (app *application) postContextMiddleware(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
-		post, err := app.store.Posts.GetByID(ctx, id)
+		post, err := app.getPost(ctx, id)
 
 		if err != nil {
 			switch {
@@ -154,12 +255,132 @@ func (app *application) postContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		viewer := getAuthUserFromContext(r)
+
+		visible, err := app.canViewPost(ctx, post, viewer)
+
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		blocked, err := app.blockedBetween(ctx, viewer.ID, post.UserID)
+
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		// Hidden posts look like missing ones, so their existence doesn't
+		// leak. That includes the posts of users the viewer blocked or was
+		// blocked by, and with them their comments and reactions.
+		if !visible || blocked {
+			app.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postKey, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// canViewPost reports whether viewer may see post, going by its visibility
+// and whether the author's account is private. Roles grant nothing here, so
+// GET agrees with the feed and search.
+func (app *application) canViewPost(ctx context.Context, post *store.Post, viewer *store.User) (bool, error) {
+	if post.UserID == viewer.ID {
+		return true, nil
+	}
+
+	author, err := app.getUser(ctx, post.UserID)
+
+	if err != nil {
+		return false, err
+	}
+
+	following := false
+
+	// Only these cases depend on the follow, so the others skip the query.
+	if author.IsPrivate || post.Visibility == store.VisibilityFollowers {
+		following, err = app.store.Followers.IsFollowing(ctx, viewer.ID, author.ID)
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return post.VisibleTo(viewer.ID, author.IsPrivate, following), nil
+}
+
+// blockedBetween reports whether either user blocked the other. Nobody can
+// block themselves, so it skips the query when both are the same user.
+func (app *application) blockedBetween(ctx context.Context, userID, otherID int64) (bool, error) {
+	if userID == otherID {
+		return false, nil
+	}
+
+	return app.store.Blocks.IsBlocked(ctx, userID, otherID)
+}
+
+// getPost is the post counterpart of getUser.
+func (app *application) getPost(ctx context.Context, postID int64) (*store.Post, error) {
+	if !app.config.cache.enabled {
+		return app.store.Posts.GetByID(ctx, postID)
+	}
+
+	post, err := app.cache.Posts.Get(ctx, postID)
+
+	if err != nil {
+		app.ctxLogger(ctx).Warnw("Post cache read failed", "post_id", postID, "error", err.Error())
+	}
+
+	if post != nil {
+		return post, nil
+	}
+
+	post, err = app.store.Posts.GetByID(ctx, postID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.cache.Posts.Set(ctx, post); err != nil {
+		app.ctxLogger(ctx).Warnw("Post cache write failed", "post_id", postID, "error", err.Error())
+	}
+
+	return post, nil
+}
+
+func (app *application) invalidatePost(ctx context.Context, postID int64) {
+	if !app.config.cache.enabled {
+		return
+	}
+
+	if err := app.cache.Posts.Delete(ctx, postID); err != nil {
+		app.ctxLogger(ctx).Warnw("Post cache invalidation failed", "post_id", postID, "error", err.Error())
+	}
+}
+
 func getPostFromCtx(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postKey).(*store.Post)
 	return post
 }
+
+func postETag(post *store.Post) string {
+	return `"` + strconv.Itoa(post.Version) + `"`
+}
+
+// etagMatches implements the If-Match comparison: "*" matches any current
+// representation, otherwise one of the listed entity tags must equal etag.
+// Weak tags never match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
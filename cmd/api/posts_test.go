@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store/cache"
+)
+
+func TestGetPostNotFound(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+
+	alice.expect(http.StatusNotFound, http.MethodGet, "/v1/posts/42", nil)
+}
+
+func TestUpdatePostVersionConflict(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+
+	post := alice.createPost("first")
+	path := fmt.Sprintf("/v1/posts/%d", post.ID)
+
+	etag := alice.expect(http.StatusOK, http.MethodGet, path, nil).header.Get("ETag")
+
+	alice.expect(http.StatusPreconditionRequired, http.MethodPatch, path, map[string]string{"title": "second"})
+	alice.expect(http.StatusOK, http.MethodPatch, path, map[string]string{"title": "second"}, "If-Match", etag)
+
+	// The first update changed the version, so the same ETag is now stale.
+	alice.expect(http.StatusPreconditionFailed, http.MethodPatch, path, map[string]string{"title": "third"}, "If-Match", etag)
+}
+
+func TestBlockedUsersCannotReachEachOthersPosts(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+	bob := registerUser(t, h, "bobby")
+
+	post := alice.createPost("first")
+	path := fmt.Sprintf("/v1/posts/%d", post.ID)
+
+	var comment struct {
+		ID int64 `json:"id"`
+	}
+
+	bob.expect(http.StatusCreated, http.MethodPost, path+"/comments", map[string]string{"content": "hi"}).decode(t, &comment)
+
+	alice.expect(http.StatusNoContent, http.MethodPut, fmt.Sprintf("/v1/users/%d/block", bob.id), nil)
+
+	bob.expect(http.StatusNotFound, http.MethodGet, path, nil)
+	bob.expect(http.StatusNotFound, http.MethodPost, path+"/comments", map[string]string{"content": "hi again"})
+	bob.expect(http.StatusNotFound, http.MethodPut, path+"/reactions/like", nil)
+
+	// The block hides bob's comment from alice as well.
+	alice.expect(http.StatusNotFound, http.MethodPut, fmt.Sprintf("%s/comments/%d/reactions/like", path, comment.ID), nil)
+	alice.expect(http.StatusBadRequest, http.MethodPost, path+"/comments", map[string]any{"content": "reply", "parent_id": comment.ID})
+}
+
+func TestMentionedPostsOfPrivateAccountsNeedAFollow(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+	bob := registerUser(t, h, "bobby")
+
+	alice.expect(http.StatusOK, http.MethodPatch, fmt.Sprintf("/v1/users/%d", alice.id), map[string]bool{"is_private": true})
+
+	var post struct {
+		ID int64 `json:"id"`
+	}
+
+	alice.expect(http.StatusOK, http.MethodPost, "/v1/posts", map[string]string{
+		"title":      "hello",
+		"content":    "hi @bobby",
+		"visibility": "mentioned",
+	}).decode(t, &post)
+
+	path := fmt.Sprintf("/v1/posts/%d", post.ID)
+
+	bob.expect(http.StatusNotFound, http.MethodGet, path, nil)
+
+	bob.expect(http.StatusAccepted, http.MethodPut, fmt.Sprintf("/v1/users/%d/follow", alice.id), nil)
+	alice.expect(http.StatusNoContent, http.MethodPut, fmt.Sprintf("/v1/users/follow-requests/%d", bob.id), nil)
+
+	bob.expect(http.StatusOK, http.MethodGet, path, nil)
+}
+
+func TestCachedPostsAreInvalidated(t *testing.T) {
+	app := newTestApp(t)
+	app.config.cache.enabled = true
+	app.cache = cache.NewLRUStorage(10, time.Minute)
+
+	h := app.mount()
+	alice := registerUser(t, h, "alice")
+
+	post := alice.createPost("first")
+	path := fmt.Sprintf("/v1/posts/%d", post.ID)
+
+	etag := alice.expect(http.StatusOK, http.MethodGet, path, nil).header.Get("ETag")
+	alice.expect(http.StatusOK, http.MethodPatch, path, map[string]string{"title": "second"}, "If-Match", etag)
+
+	var got store.Post
+	alice.expect(http.StatusOK, http.MethodGet, path, nil).decode(t, &got)
+
+	if got.Title != "second" {
+		t.Errorf("got title %q after the update, want %q", got.Title, "second")
+	}
+
+	alice.expect(http.StatusNoContent, http.MethodDelete, path, nil)
+	alice.expect(http.StatusNotFound, http.MethodGet, path, nil)
+}
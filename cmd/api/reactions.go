@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+func (app *application) addPostReactionHandler(w http.ResponseWriter, r *http.Request) {
+	app.reactionHandler(w, r, store.PostReaction, getPostFromCtx(r).ID, app.store.Reactions.Add)
+}
+
+func (app *application) removePostReactionHandler(w http.ResponseWriter, r *http.Request) {
+	app.reactionHandler(w, r, store.PostReaction, getPostFromCtx(r).ID, app.store.Reactions.Remove)
+}
+
+func (app *application) addCommentReactionHandler(w http.ResponseWriter, r *http.Request) {
+	app.reactionHandler(w, r, store.CommentReaction, getCommentFromCtx(r).ID, app.store.Reactions.Add)
+}
+
+func (app *application) removeCommentReactionHandler(w http.ResponseWriter, r *http.Request) {
+	app.reactionHandler(w, r, store.CommentReaction, getCommentFromCtx(r).ID, app.store.Reactions.Remove)
+}
+
+type reactionFunc func(ctx context.Context, target store.ReactionTarget, targetID, userID int64, reaction string) error
+
+// reactionHandler applies the {type} reaction of the authenticated user with
+// fn. Both adding and removing are idempotent and answer 204.
+func (app *application) reactionHandler(w http.ResponseWriter, r *http.Request, target store.ReactionTarget, targetID int64, fn reactionFunc) {
+	user := getAuthUserFromContext(r)
+	reaction := chi.URLParam(r, "type")
+
+	if err := fn(r.Context(), target, targetID, user.ID, reaction); err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidReaction):
+			app.badRequestError(w, r, clientError("reaction must be one of: "+strings.Join(store.ReactionTypes, " ")))
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attachCommentReactions fills in the reactions of every comment as seen by
+// viewerID, with a single store call.
+func (app *application) attachCommentReactions(ctx context.Context, comments []store.Comment, viewerID int64) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(comments))
+
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	summaries, err := app.store.Reactions.Summaries(ctx, store.CommentReaction, ids, viewerID)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comments[i].Reactions = summaries[comments[i].ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	sq := store.SearchQuery{
+		Type:     store.SearchPosts,
+		Limit:    20,
+		Offset:   0,
+		ViewerID: getAuthUserFromContext(r).ID,
+	}
+
+	sq, err := sq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	scope := searchCursorScope(sq)
+
+	if sq.Cursor != "" {
+		position, err := app.decodeCursor(sq.Cursor, scope)
+
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+
+		sq.Position = position
+		sq.Offset = 0
+	}
+
+	results, err := app.store.Search.Search(r.Context(), sq)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	next, prev, err := app.pageCursors(sq.Position, sq.Offset, sq.Limit, len(results), func(i int) (store.Cursor, error) {
+		return store.Cursor{
+			Rank:  results[i].Rank,
+			ID:    results[i].ID,
+			Sort:  "desc",
+			Scope: scope,
+		}, nil
+	})
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, results, next, prev); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// searchCursorScope ties search cursors to the query and type they were
+// issued for. The query is hashed to keep cursors short.
+func searchCursorScope(sq store.SearchQuery) string {
+	sum := sha256.Sum256([]byte(sq.Query))
+	return "search:" + sq.Type + ":" + hex.EncodeToString(sum[:12])
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+func TestSearchEscapesSnippets(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+
+	alice.expect(http.StatusOK, http.MethodPost, "/v1/posts", map[string]string{
+		"title":   "payload",
+		"content": `<script>alert("payload")</script>`,
+	})
+
+	var results []*store.SearchResult
+	alice.expect(http.StatusOK, http.MethodGet, "/v1/search?type=posts&q=payload", nil).decode(t, &results)
+
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	if snippet := results[0].Snippet; strings.Contains(snippet, "<script>") {
+		t.Errorf("snippet %q contains unescaped markup", snippet)
+	}
+}
+
+func TestSearchHidesBlockedUsers(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+	bob := registerUser(t, h, "bobby")
+
+	post := alice.createPost("findable")
+
+	bob.expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/v1/posts/%d/comments", post.ID), map[string]string{"content": "findable too"})
+
+	alice.expect(http.StatusNoContent, http.MethodPut, fmt.Sprintf("/v1/users/%d/block", bob.id), nil)
+
+	for _, c := range []*testClient{alice, bob} {
+		for _, typ := range []string{store.SearchPosts, store.SearchComments} {
+			var results []*store.SearchResult
+			c.expect(http.StatusOK, http.MethodGet, "/v1/search?q=findable&type="+typ, nil).decode(t, &results)
+
+			for _, result := range results {
+				if result.UserID != c.id {
+					t.Errorf("user %d found %s %d by user %d across a block", c.id, typ, result.ID, result.UserID)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+// trashHandler lists the authenticated user's posts and comments that were
+// deleted, by them or by a moderator, and can still be restored, most
+// recently deleted first.
+func (app *application) trashHandler(w http.ResponseWriter, r *http.Request) {
+	tq := store.TrashQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+
+	tq, err := tq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(tq); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := getAuthUserFromContext(r)
+
+	items, err := app.store.Trash.List(r.Context(), user.ID, app.config.trash.retention, tq)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, items); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// purgeTrash permanently removes expired trash every purge interval until
+// ctx is done. Running it on several instances at once is harmless.
+func (app *application) purgeTrash(ctx context.Context) {
+	ticker := time.NewTicker(app.config.trash.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		app.purgeExpiredTrash(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) purgeExpiredTrash(ctx context.Context) {
+	posts, comments, err := app.store.Trash.Purge(ctx, app.config.trash.retention)
+
+	if err != nil {
+		app.logger.Errorw("Trash purge failed", "error", err.Error())
+		return
+	}
+
+	if posts > 0 || comments > 0 {
+		app.logger.Infow("Trash purged", "posts", posts, "comments", comments)
+	}
+}
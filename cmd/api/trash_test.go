@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+func TestModeratedPostsGoToTheAuthorsTrash(t *testing.T) {
+	app := newTestApp(t)
+	h := app.mount()
+	alice := registerUser(t, h, "alice")
+	admin := registerUserWithRole(t, app, h, "admin", "admin")
+
+	post := alice.createPost("first")
+	path := fmt.Sprintf("/v1/posts/%d", post.ID)
+
+	admin.expect(http.StatusNoContent, http.MethodDelete, path, nil)
+
+	var items []*store.TrashItem
+	alice.expect(http.StatusOK, http.MethodGet, "/v1/me/trash", nil).decode(t, &items)
+
+	if len(items) != 1 || items[0].ID != post.ID || items[0].DeletedBy != admin.id {
+		t.Fatalf("got trash %+v, want post %d deleted by %d", items, post.ID, admin.id)
+	}
+
+	admin.expect(http.StatusOK, http.MethodGet, "/v1/me/trash", nil).decode(t, &items)
+
+	if len(items) != 0 {
+		t.Errorf("got %d items in the admin's trash, want none", len(items))
+	}
+
+	alice.expect(http.StatusOK, http.MethodPost, path+"/restore", nil)
+	alice.expect(http.StatusOK, http.MethodGet, path, nil)
+}
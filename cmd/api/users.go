@@ -11,68 +11,195 @@ import (
 
 type userContextKey string
 
-type FollowUser struct {
-	UserID int64 `json:"user_id"`
-}
-
 const (
-	userCtxKey userContextKey = "user"
+	userCtxKey     userContextKey = "user"
+	authUserCtxKey userContextKey = "authUser"
 )
 
+// userProfile is a user along with their follow counts and how they relate
+// to the caller. The stats are never cached with the user, as they depend on
+// who is asking.
+type userProfile struct {
+	*store.User
+	*store.FollowStats
+}
+
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromContext(r)
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	stats, err := app.store.Followers.Stats(r.Context(), user.ID, getAuthUserFromContext(r).ID)
+
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, userProfile{user, stats}); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
-func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
-	toBeFollowedUser := getUserFromContext(r)
+type UpdateUserPayload struct {
+	IsPrivate *bool `json:"is_private" validate:"required"`
+}
+
+// updateUserHandler lets users change their own account settings.
+func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	if user.ID != getAuthUserFromContext(r).ID {
+		app.forbiddenError(w, r)
+		return
+	}
+
+	var payload UpdateUserPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
 
-	var currUser FollowUser // TODO: needs to be removed when authentication is added
-	if err := readJSON(w, r, &currUser); err != nil {
+	if err := Validate.Struct(payload); err != nil {
 		app.badRequestError(w, r, err)
 		return
 	}
 
 	ctx := r.Context()
 
-	if err := app.store.Followers.Follow(ctx, toBeFollowedUser.ID, currUser.UserID); err != nil {
+	if err := app.store.Users.SetPrivate(ctx, user.ID, *payload.IsPrivate); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.invalidateUsers(ctx, user.ID)
+
+	user.IsPrivate = *payload.IsPrivate
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// followUserHandler follows a public account outright and answers 204. A
+// private account gets a follow request instead, which answers 202 until the
+// owner approves it. The store decides which from the account as it is in the
+// database, not from the cached user.
+func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	toBeFollowedUser := getUserFromContext(r)
+	currUser := getAuthUserFromContext(r)
+
+	ctx := r.Context()
+
+	requested, err := app.store.Followers.Follow(ctx, toBeFollowedUser.ID, currUser.ID)
+
+	if err != nil {
 		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+			return
 		case store.ErrConflict:
 			app.conflictError(w, r, err)
 			return
+		case store.ErrBlocked:
+			app.forbiddenError(w, r)
+			return
 		default:
 			app.internalServerError(w, r, err)
 			return
 		}
 	}
 
-	if err := app.jsonResponse(w, http.StatusNoContent, toBeFollowedUser); err != nil {
-		app.internalServerError(w, r, err)
+	if requested {
+		if err := app.jsonResponse(w, http.StatusAccepted, map[string]string{"status": "requested"}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
+
+	app.invalidateUsers(ctx, toBeFollowedUser.ID, currUser.ID)
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
 	toBeUnfollowedUser := getUserFromContext(r)
+	currUser := getAuthUserFromContext(r)
 
-	var currUser FollowUser // TODO: needs to be removed when authentication is added
-	if err := readJSON(w, r, &currUser); err != nil {
-		app.badRequestError(w, r, err)
+	ctx := r.Context()
+
+	if err := app.store.Followers.Unfollow(ctx, toBeUnfollowedUser.ID, currUser.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.invalidateUsers(ctx, toBeUnfollowedUser.ID, currUser.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.blockHandler(w, r, "block", app.store.Blocks.Block)
+}
+
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.blockHandler(w, r, "block", app.store.Blocks.Unblock)
+}
+
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.blockHandler(w, r, "mute", app.store.Blocks.Mute)
+}
+
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.blockHandler(w, r, "mute", app.store.Blocks.Unmute)
+}
+
+// blockHandler applies fn from the authenticated user to the user in the URL.
+// Blocking drops follows, so both users are evicted from the cache.
+func (app *application) blockHandler(w http.ResponseWriter, r *http.Request, action string, fn func(ctx context.Context, userID, targetID int64) error) {
+	target := getUserFromContext(r)
+	currUser := getAuthUserFromContext(r)
+
+	if target.ID == currUser.ID {
+		app.badRequestError(w, r, clientError("you cannot "+action+" yourself"))
 		return
 	}
 
 	ctx := r.Context()
 
-	if err := app.store.Followers.Unfollow(ctx, toBeUnfollowedUser.ID, currUser.UserID); err != nil {
-		app.internalServerError(w, r, err)
+	if err := fn(ctx, currUser.ID, target.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusNoContent, toBeUnfollowedUser); err != nil {
-		app.internalServerError(w, r, err)
+	app.invalidateUsers(ctx, target.ID, currUser.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	if err := app.store.Users.Activate(r.Context(), token); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (app *application) userContextMIddleware(next http.Handler) http.Handler {
@@ -85,7 +212,7 @@ func (app *application) userContextMIddleware(next http.Handler) http.Handler {
 		}
 
 		ctx := r.Context()
-		user, err := app.store.Users.GetByID(ctx, userID)
+		user, err := app.getUser(ctx, userID)
 
 		if err != nil {
 			switch err {
@@ -108,3 +235,55 @@ func getUserFromContext(r *http.Request) *store.User {
 
 	return user
 }
+
+func getAuthUserFromContext(r *http.Request) *store.User {
+	user, _ := r.Context().Value(authUserCtxKey).(*store.User)
+
+	return user
+}
+
+// getUser reads through the user cache when it is enabled. Cache failures
+// are logged and fall back to the database.
+func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
+	if !app.config.cache.enabled {
+		return app.store.Users.GetByID(ctx, userID)
+	}
+
+	user, err := app.cache.Users.Get(ctx, userID)
+
+	if err != nil {
+		app.ctxLogger(ctx).Warnw("User cache read failed", "user_id", userID, "error", err.Error())
+	}
+
+	if user != nil {
+		return user, nil
+	}
+
+	user, err = app.store.Users.GetByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Activation goes by token and can't invalidate by id, so inactive users
+	// are never cached.
+	if user.IsActive {
+		if err := app.cache.Users.Set(ctx, user); err != nil {
+			app.ctxLogger(ctx).Warnw("User cache write failed", "user_id", userID, "error", err.Error())
+		}
+	}
+
+	return user, nil
+}
+
+func (app *application) invalidateUsers(ctx context.Context, userIDs ...int64) {
+	if !app.config.cache.enabled {
+		return
+	}
+
+	for _, id := range userIDs {
+		if err := app.cache.Users.Delete(ctx, id); err != nil {
+			app.ctxLogger(ctx).Warnw("User cache invalidation failed", "user_id", id, "error", err.Error())
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store/cache"
+)
+
+func TestFollowTwiceConflicts(t *testing.T) {
+	h := newTestApp(t).mount()
+	alice := registerUser(t, h, "alice")
+	bob := registerUser(t, h, "bobby")
+
+	path := fmt.Sprintf("/v1/users/%d/follow", alice.id)
+
+	bob.expect(http.StatusNoContent, http.MethodPut, path, nil)
+	bob.expect(http.StatusConflict, http.MethodPut, path, nil)
+}
+
+func TestFollowPrivateAccountThroughStaleCache(t *testing.T) {
+	app := newTestApp(t)
+	app.config.cache.enabled = true
+	app.cache = cache.NewLRUStorage(10, time.Minute)
+
+	h := app.mount()
+	alice := registerUser(t, h, "alice")
+	bob := registerUser(t, h, "bobby")
+
+	// Cache alice as a public account, then make the account private behind
+	// the cache's back.
+	bob.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/v1/users/%d", alice.id), nil)
+
+	if err := app.store.Users.SetPrivate(context.Background(), alice.id, true); err != nil {
+		t.Fatal(err)
+	}
+
+	bob.expect(http.StatusAccepted, http.MethodPut, fmt.Sprintf("/v1/users/%d/follow", alice.id), nil)
+
+	following, err := app.store.Followers.IsFollowing(context.Background(), bob.id, alice.id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if following {
+		t.Error("following a private account did not wait for approval")
+	}
+}
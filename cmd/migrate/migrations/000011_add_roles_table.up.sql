@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id bigserial PRIMARY KEY,
+    name varchar(255) NOT NULL UNIQUE,
+    level int NOT NULL DEFAULT 0,
+    description text NOT NULL DEFAULT ''
+);
+
+INSERT INTO
+    roles (name, level, description)
+VALUES
+    ('user', 1, 'A user can create posts and comments'),
+    ('moderator', 2, 'A moderator can update other users posts'),
+    ('admin', 3, 'An admin can update and delete other users posts');
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE comments
+ADD COLUMN parent_id bigint REFERENCES comments (id) ON DELETE CASCADE;
+
+ALTER TABLE comments
+ADD COLUMN updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id);
\ No newline at end of file
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_users_username_trgm;
+
+DROP INDEX IF EXISTS idx_users_search_vector;
+DROP INDEX IF EXISTS idx_comments_search_vector;
+DROP INDEX IF EXISTS idx_posts_search_vector;
+
+ALTER TABLE users
+DROP COLUMN search_vector;
+
+ALTER TABLE comments
+DROP COLUMN search_vector;
+
+ALTER TABLE posts
+DROP COLUMN search_vector;
\ No newline at end of file
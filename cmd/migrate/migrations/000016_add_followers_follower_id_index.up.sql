@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id ON followers (follower_id);
\ No newline at end of file
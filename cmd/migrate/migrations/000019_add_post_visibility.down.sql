@@ -0,0 +1,5 @@
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS mentioned_user_ids,
+    DROP COLUMN IF EXISTS visibility;
+
+DROP TYPE IF EXISTS post_visibility;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TYPE post_visibility AS ENUM ('public', 'followers', 'mentioned', 'private');
+
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS visibility post_visibility NOT NULL DEFAULT 'public',
+    ADD COLUMN IF NOT EXISTS mentioned_user_ids bigint[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_posts_mentioned_user_ids ON posts USING gin (mentioned_user_ids);
\ No newline at end of file
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_comments_deleted_at;
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+-- Trashed rows would otherwise come back to life.
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+DELETE FROM comments WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comments_post_id;
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
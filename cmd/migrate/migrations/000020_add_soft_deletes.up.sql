@@ -0,0 +1,18 @@
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone,
+    ADD COLUMN IF NOT EXISTS deleted_by bigint REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone,
+    ADD COLUMN IF NOT EXISTS deleted_by bigint REFERENCES users (id) ON DELETE SET NULL;
+
+-- Posts used to be hard-deleted, which could leave their comments behind.
+DELETE FROM comments c
+WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id);
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_post_id FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE;
+
+-- Only trashed rows are indexed, for the trash listing and the purger.
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at) WHERE deleted_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- The feed search filter matches posts.content with ILIKE, which only the
+-- title had a trigram index for.
+CREATE INDEX IF NOT EXISTS idx_post_content ON posts USING gin (content gin_trgm_ops);
\ No newline at end of file
@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL migration files so they ship inside the
+// binaries that apply them.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type JWTAuthenticator struct {
+	method  jwt.SigningMethod
+	signKey any
+	verKey  any
+	aud     string
+	iss     string
+}
+
+// NewJWTAuthenticator signs and verifies tokens with a shared HS256 secret.
+func NewJWTAuthenticator(secret, aud, iss string) (*JWTAuthenticator, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("jwt: empty HS256 secret")
+	}
+
+	return &JWTAuthenticator{
+		method:  jwt.SigningMethodHS256,
+		signKey: []byte(secret),
+		verKey:  []byte(secret),
+		aud:     aud,
+		iss:     iss,
+	}, nil
+}
+
+// NewRSAJWTAuthenticator signs tokens with an RS256 private key and verifies
+// them with the matching public key. Both keys are PEM encoded.
+func NewRSAJWTAuthenticator(privateKeyPEM, publicKeyPEM, aud, iss string) (*JWTAuthenticator, error) {
+	var (
+		privateKey *rsa.PrivateKey
+		publicKey  *rsa.PublicKey
+		err        error
+	)
+
+	privateKey, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKeyPEM))
+
+	if err != nil {
+		return nil, fmt.Errorf("jwt: parsing RS256 private key: %w", err)
+	}
+
+	if publicKeyPEM == "" {
+		publicKey = &privateKey.PublicKey
+	} else {
+		publicKey, err = jwt.ParseRSAPublicKeyFromPEM([]byte(publicKeyPEM))
+
+		if err != nil {
+			return nil, fmt.Errorf("jwt: parsing RS256 public key: %w", err)
+		}
+	}
+
+	return &JWTAuthenticator{
+		method:  jwt.SigningMethodRS256,
+		signKey: privateKey,
+		verKey:  publicKey,
+		aud:     aud,
+		iss:     iss,
+	}, nil
+}
+
+func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(a.method, claims)
+
+	return token.SignedString(a.signKey)
+}
+
+func (a *JWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+	return jwt.Parse(
+		token,
+		func(t *jwt.Token) (any, error) {
+			if t.Method.Alg() != a.method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+
+			return a.verKey, nil
+		},
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(a.aud),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods([]string{a.method.Alg()}),
+	)
+}
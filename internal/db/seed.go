@@ -0,0 +1,296 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+// SeedPassword is the password of every seeded user.
+const SeedPassword = "password"
+
+type SeedConfig struct {
+	Users    int
+	Posts    int
+	Comments int
+	Follows  int
+	Seed     uint64
+
+	// Now anchors the generated timestamps, which all lie in the past of it.
+	// The zero value means the current time.
+	Now time.Time
+}
+
+var (
+	adjectives = []string{
+		"quiet", "brave", "lucky", "sunny", "clever", "wild", "gentle", "rapid",
+		"silent", "cosmic", "golden", "frozen", "hidden", "mellow", "nimble", "rusty",
+	}
+
+	nouns = []string{
+		"gopher", "falcon", "otter", "maple", "river", "comet", "badger", "willow",
+		"harbor", "pixel", "tiger", "ember", "canyon", "lotus", "raven", "cedar",
+	}
+
+	tags = []string{
+		"go", "postgres", "docker", "backend", "frontend", "devops", "testing",
+		"performance", "security", "career", "tutorial", "opinion", "news", "tools",
+	}
+
+	titles = []string{
+		"Why I switched to %s",
+		"A practical guide to %s",
+		"Lessons learned from a year of %s",
+		"%s in production: what went wrong",
+		"Stop overthinking %s",
+		"The hidden cost of %s",
+		"Getting started with %s",
+		"%s tips nobody told me about",
+	}
+
+	sentences = []string{
+		"This turned out to be much simpler than I expected.",
+		"The documentation glosses over a few important details.",
+		"Benchmarks told a different story than the blog posts.",
+		"Most of the complexity came from the edge cases.",
+		"I would do it the same way again, with one exception.",
+		"Our team spent a week chasing a single missing index.",
+		"Keep the happy path boring and the failure modes loud.",
+		"Measure first, then decide what to optimize.",
+		"The tooling around it has improved a lot recently.",
+		"Small, well-named functions beat clever abstractions.",
+	}
+
+	replies = []string{
+		"Great write-up, thanks for sharing!",
+		"I ran into the same issue last month.",
+		"Could you share the benchmark setup?",
+		"Strongly disagree with the second point.",
+		"This saved me hours, bookmarked.",
+		"Have you tried doing it with a transaction instead?",
+		"Interesting, but how does it scale?",
+		"Nice, the example made it click for me.",
+	}
+)
+
+// Seed fills the database with deterministic fake data: running it twice
+// with the same config on an empty database produces the same rows. It all
+// happens in one transaction, so a failed run leaves nothing behind.
+func Seed(ctx context.Context, s store.Storage, cfg SeedConfig) error {
+	rng := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x9e3779b97f4a7c15))
+	now := cfg.Now
+
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	now = now.Truncate(time.Second)
+
+	var (
+		users             []*store.User
+		posts             []*store.Post
+		comments, follows int
+	)
+
+	err := s.Tx.WithTx(ctx, func(tx store.Storage) error {
+		var err error
+
+		if users, err = seedUsers(ctx, tx, rng, cfg.Users, now); err != nil {
+			return fmt.Errorf("seeding users: %w", err)
+		}
+
+		if posts, err = seedPosts(ctx, tx, rng, users, cfg.Posts, now); err != nil {
+			return fmt.Errorf("seeding posts: %w", err)
+		}
+
+		if comments, err = seedComments(ctx, tx, rng, users, posts, cfg.Comments, now); err != nil {
+			return fmt.Errorf("seeding comments: %w", err)
+		}
+
+		if follows, err = seedFollows(ctx, tx, rng, users, cfg.Follows); err != nil {
+			return fmt.Errorf("seeding followers: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("seeded %d users, %d posts, %d comments and %d follow edges", len(users), len(posts), comments, follows)
+
+	return nil
+}
+
+func seedUsers(ctx context.Context, s store.Storage, rng *rand.Rand, n int, now time.Time) ([]*store.User, error) {
+	// Hashing once keeps seeding fast; bcrypt is deliberately slow.
+	var hashed store.User
+
+	if err := hashed.Password.Set(SeedPassword); err != nil {
+		return nil, err
+	}
+
+	users := make([]*store.User, n)
+
+	for i := range users {
+		username := fmt.Sprintf("%s_%s_%d", pick(rng, adjectives), pick(rng, nouns), i+1)
+
+		users[i] = &store.User{
+			Username:  username,
+			Email:     username + "@example.com",
+			Password:  hashed.Password,
+			IsActive:  true,
+			CreatedAt: randomTime(rng, now, 365*24*time.Hour),
+		}
+	}
+
+	if len(users) > 0 {
+		users[0].Role.Name = "admin"
+	}
+
+	if len(users) > 1 {
+		users[1].Role.Name = "moderator"
+	}
+
+	return users, s.Users.CreateBatch(ctx, users)
+}
+
+func seedPosts(ctx context.Context, s store.Storage, rng *rand.Rand, users []*store.User, n int, now time.Time) ([]*store.Post, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	posts := make([]*store.Post, n)
+
+	for i := range posts {
+		postTags := pickN(rng, tags, 1+rng.IntN(3))
+		paragraph := make([]string, 2+rng.IntN(4))
+
+		for j := range paragraph {
+			paragraph[j] = pick(rng, sentences)
+		}
+
+		title := fmt.Sprintf(pick(rng, titles), postTags[0])
+		author := pick(rng, users)
+
+		// Both are RFC 3339 in the same zone, so they compare as strings.
+		createdAt := max(randomTime(rng, now, 90*24*time.Hour), author.CreatedAt)
+
+		posts[i] = &store.Post{
+			Title:     title,
+			UserID:    author.ID,
+			Content:   strings.Join(paragraph, " "),
+			Tags:      postTags,
+			CreatedAt: createdAt,
+		}
+	}
+
+	return posts, s.Posts.CreateBatch(ctx, posts)
+}
+
+func seedComments(ctx context.Context, s store.Storage, rng *rand.Rand, users []*store.User, posts []*store.Post, n int, now time.Time) (int, error) {
+	if len(users) == 0 || len(posts) == 0 {
+		return 0, nil
+	}
+
+	// Top-level comments go in a first batch so that replies can point at
+	// their ids in the second one.
+	roots := make([]*store.Comment, 0, n)
+	var replyCount int
+
+	for range n {
+		if len(roots) > 0 && rng.IntN(3) == 0 {
+			replyCount++
+			continue
+		}
+
+		post := pick(rng, posts)
+
+		roots = append(roots, &store.Comment{
+			PostID:    post.ID,
+			UserID:    pick(rng, users).ID,
+			Content:   pick(rng, replies),
+			CreatedAt: max(randomTime(rng, now, 30*24*time.Hour), post.CreatedAt),
+		})
+	}
+
+	if err := s.Comments.CreateBatch(ctx, roots); err != nil {
+		return 0, err
+	}
+
+	replyBatch := make([]*store.Comment, replyCount)
+
+	for i := range replyBatch {
+		parent := pick(rng, roots)
+		parentID := parent.ID
+
+		// Both are RFC 3339 in the same zone, so they compare as strings.
+		createdAt := max(randomTime(rng, now, 7*24*time.Hour), parent.CreatedAt)
+
+		replyBatch[i] = &store.Comment{
+			PostID:    parent.PostID,
+			UserID:    pick(rng, users).ID,
+			ParentID:  &parentID,
+			Content:   pick(rng, replies),
+			CreatedAt: createdAt,
+		}
+	}
+
+	if err := s.Comments.CreateBatch(ctx, replyBatch); err != nil {
+		return 0, err
+	}
+
+	return len(roots) + len(replyBatch), nil
+}
+
+func seedFollows(ctx context.Context, s store.Storage, rng *rand.Rand, users []*store.User, n int) (int, error) {
+	if len(users) < 2 {
+		return 0, nil
+	}
+
+	seen := map[[2]int64]bool{}
+	follows := []store.Follower{}
+
+	// Give up after a bounded number of attempts when n is close to the
+	// number of possible edges.
+	for attempts := 0; len(follows) < n && attempts < n*10; attempts++ {
+		follower, followed := pick(rng, users), pick(rng, users)
+		key := [2]int64{follower.ID, followed.ID}
+
+		if follower.ID == followed.ID || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		follows = append(follows, store.Follower{UserID: follower.ID, FollowerID: followed.ID})
+	}
+
+	return len(follows), s.Followers.FollowBatch(ctx, follows)
+}
+
+func pick[T any](rng *rand.Rand, items []T) T {
+	return items[rng.IntN(len(items))]
+}
+
+func pickN[T any](rng *rand.Rand, items []T, n int) []T {
+	picked := make([]T, 0, n)
+
+	for _, i := range rng.Perm(len(items))[:min(n, len(items))] {
+		picked = append(picked, items[i])
+	}
+
+	return picked
+}
+
+// randomTime returns a timestamp up to maxAge before now, formatted the way
+// the store models carry times.
+func randomTime(rng *rand.Rand, now time.Time, maxAge time.Duration) string {
+	age := time.Duration(rng.Int64N(int64(maxAge/time.Second))) * time.Second
+	return now.Add(-age).Format(time.RFC3339)
+}
@@ -34,3 +34,21 @@ func GetInt(key string, fallback int) int {
 
 	return intVal
 }
+
+func GetBool(key string, fallback bool) bool {
+	val, ok := os.LookupEnv(key)
+
+	if !ok {
+		log.Printf("Warning: Environment variable %s not set, using fallback: %t", key, fallback)
+		return fallback
+	}
+
+	boolVal, err := strconv.ParseBool(val)
+
+	if err != nil {
+		log.Printf("Error: Invalid value for %s: %s, using fallback: %t", key, val, fallback)
+		return fallback
+	}
+
+	return boolVal
+}
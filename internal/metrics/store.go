@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+// InstrumentStorage wraps every store of s so that each method call is
+// timed. Storages handed out by Tx.WithTx are instrumented as well.
+func (m *Metrics) InstrumentStorage(s store.Storage) store.Storage {
+	return store.Storage{
+		Posts:     &postStore{s, m},
+		Users:     &userStore{s, m},
+		Comments:  &commentStore{s, m},
+		Followers: &followerStore{s, m},
+		Roles:     &roleStore{s, m},
+		Search:    &searchStore{s, m},
+		Reactions: &reactionStore{s, m},
+		Blocks:    &blockStore{s, m},
+		Trash:     &trashStore{s, m},
+		Tx:        &txStore{s, m},
+	}
+}
+
+type postStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *postStore) Create(ctx context.Context, post *store.Post) (err error) {
+	defer s.m.timeQuery("posts", "Create")(&err)
+	return s.next.Posts.Create(ctx, post)
+}
+
+func (s *postStore) GetByID(ctx context.Context, postID int64) (post *store.Post, err error) {
+	defer s.m.timeQuery("posts", "GetByID")(&err)
+	return s.next.Posts.GetByID(ctx, postID)
+}
+
+func (s *postStore) DeleteByID(ctx context.Context, postID, deletedBy int64) (err error) {
+	defer s.m.timeQuery("posts", "DeleteByID")(&err)
+	return s.next.Posts.DeleteByID(ctx, postID, deletedBy)
+}
+
+func (s *postStore) Restore(ctx context.Context, postID, userID int64, retention time.Duration) (err error) {
+	defer s.m.timeQuery("posts", "Restore")(&err)
+	return s.next.Posts.Restore(ctx, postID, userID, retention)
+}
+
+func (s *postStore) UpdateByID(ctx context.Context, post *store.Post) (err error) {
+	defer s.m.timeQuery("posts", "UpdateByID")(&err)
+	return s.next.Posts.UpdateByID(ctx, post)
+}
+
+func (s *postStore) GetUserFeed(ctx context.Context, userID int64, fq store.FeedPaginationQuery) (feed []*store.FeedRecord, err error) {
+	defer s.m.timeQuery("posts", "GetUserFeed")(&err)
+	return s.next.Posts.GetUserFeed(ctx, userID, fq)
+}
+
+func (s *postStore) CreateBatch(ctx context.Context, posts []*store.Post) (err error) {
+	defer s.m.timeQuery("posts", "CreateBatch")(&err)
+	return s.next.Posts.CreateBatch(ctx, posts)
+}
+
+type userStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *userStore) Create(ctx context.Context, user *store.User) (err error) {
+	defer s.m.timeQuery("users", "Create")(&err)
+	return s.next.Users.Create(ctx, user)
+}
+
+func (s *userStore) GetByID(ctx context.Context, userID int64) (user *store.User, err error) {
+	defer s.m.timeQuery("users", "GetByID")(&err)
+	return s.next.Users.GetByID(ctx, userID)
+}
+
+func (s *userStore) GetByEmail(ctx context.Context, email string) (user *store.User, err error) {
+	defer s.m.timeQuery("users", "GetByEmail")(&err)
+	return s.next.Users.GetByEmail(ctx, email)
+}
+
+func (s *userStore) CreateAndInvite(ctx context.Context, user *store.User, token string, invitationExp time.Duration) (err error) {
+	defer s.m.timeQuery("users", "CreateAndInvite")(&err)
+	return s.next.Users.CreateAndInvite(ctx, user, token, invitationExp)
+}
+
+func (s *userStore) Activate(ctx context.Context, token string) (err error) {
+	defer s.m.timeQuery("users", "Activate")(&err)
+	return s.next.Users.Activate(ctx, token)
+}
+
+func (s *userStore) CreateBatch(ctx context.Context, users []*store.User) (err error) {
+	defer s.m.timeQuery("users", "CreateBatch")(&err)
+	return s.next.Users.CreateBatch(ctx, users)
+}
+
+func (s *userStore) SetPrivate(ctx context.Context, userID int64, private bool) (err error) {
+	defer s.m.timeQuery("users", "SetPrivate")(&err)
+	return s.next.Users.SetPrivate(ctx, userID, private)
+}
+
+type commentStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *commentStore) Create(ctx context.Context, comment *store.Comment) (err error) {
+	defer s.m.timeQuery("comments", "Create")(&err)
+	return s.next.Comments.Create(ctx, comment)
+}
+
+func (s *commentStore) GetByID(ctx context.Context, commentID int64) (comment *store.Comment, err error) {
+	defer s.m.timeQuery("comments", "GetByID")(&err)
+	return s.next.Comments.GetByID(ctx, commentID)
+}
+
+func (s *commentStore) GetByPostID(ctx context.Context, postID, viewerID int64) (comments *[]store.Comment, err error) {
+	defer s.m.timeQuery("comments", "GetByPostID")(&err)
+	return s.next.Comments.GetByPostID(ctx, postID, viewerID)
+}
+
+func (s *commentStore) UpdateByID(ctx context.Context, comment *store.Comment) (err error) {
+	defer s.m.timeQuery("comments", "UpdateByID")(&err)
+	return s.next.Comments.UpdateByID(ctx, comment)
+}
+
+func (s *commentStore) DeleteByID(ctx context.Context, commentID, deletedBy int64) (err error) {
+	defer s.m.timeQuery("comments", "DeleteByID")(&err)
+	return s.next.Comments.DeleteByID(ctx, commentID, deletedBy)
+}
+
+func (s *commentStore) Restore(ctx context.Context, postID, commentID, userID int64, retention time.Duration) (err error) {
+	defer s.m.timeQuery("comments", "Restore")(&err)
+	return s.next.Comments.Restore(ctx, postID, commentID, userID, retention)
+}
+
+func (s *commentStore) CreateBatch(ctx context.Context, comments []*store.Comment) (err error) {
+	defer s.m.timeQuery("comments", "CreateBatch")(&err)
+	return s.next.Comments.CreateBatch(ctx, comments)
+}
+
+type followerStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *followerStore) Follow(ctx context.Context, followedID, userID int64) (requested bool, err error) {
+	defer s.m.timeQuery("followers", "Follow")(&err)
+	return s.next.Followers.Follow(ctx, followedID, userID)
+}
+
+func (s *followerStore) Unfollow(ctx context.Context, unfollowedID, userID int64) (err error) {
+	defer s.m.timeQuery("followers", "Unfollow")(&err)
+	return s.next.Followers.Unfollow(ctx, unfollowedID, userID)
+}
+
+func (s *followerStore) FollowBatch(ctx context.Context, follows []store.Follower) (err error) {
+	defer s.m.timeQuery("followers", "FollowBatch")(&err)
+	return s.next.Followers.FollowBatch(ctx, follows)
+}
+
+func (s *followerStore) List(ctx context.Context, list string, userID, viewerID int64, fq store.FollowListQuery) (entries []*store.FollowListEntry, err error) {
+	defer s.m.timeQuery("followers", "List")(&err)
+	return s.next.Followers.List(ctx, list, userID, viewerID, fq)
+}
+
+func (s *followerStore) Stats(ctx context.Context, userID, viewerID int64) (stats *store.FollowStats, err error) {
+	defer s.m.timeQuery("followers", "Stats")(&err)
+	return s.next.Followers.Stats(ctx, userID, viewerID)
+}
+
+func (s *followerStore) IsFollowing(ctx context.Context, userID, targetID int64) (following bool, err error) {
+	defer s.m.timeQuery("followers", "IsFollowing")(&err)
+	return s.next.Followers.IsFollowing(ctx, userID, targetID)
+}
+
+func (s *followerStore) Approve(ctx context.Context, targetID, requesterID int64) (err error) {
+	defer s.m.timeQuery("followers", "Approve")(&err)
+	return s.next.Followers.Approve(ctx, targetID, requesterID)
+}
+
+func (s *followerStore) Reject(ctx context.Context, targetID, requesterID int64) (err error) {
+	defer s.m.timeQuery("followers", "Reject")(&err)
+	return s.next.Followers.Reject(ctx, targetID, requesterID)
+}
+
+type roleStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *roleStore) GetByName(ctx context.Context, name string) (role *store.Role, err error) {
+	defer s.m.timeQuery("roles", "GetByName")(&err)
+	return s.next.Roles.GetByName(ctx, name)
+}
+
+type searchStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *searchStore) Search(ctx context.Context, q store.SearchQuery) (results []*store.SearchResult, err error) {
+	defer s.m.timeQuery("search", "Search")(&err)
+	return s.next.Search.Search(ctx, q)
+}
+
+type reactionStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *reactionStore) Add(ctx context.Context, target store.ReactionTarget, targetID, userID int64, reaction string) (err error) {
+	defer s.m.timeQuery("reactions", "Add")(&err)
+	return s.next.Reactions.Add(ctx, target, targetID, userID, reaction)
+}
+
+func (s *reactionStore) Remove(ctx context.Context, target store.ReactionTarget, targetID, userID int64, reaction string) (err error) {
+	defer s.m.timeQuery("reactions", "Remove")(&err)
+	return s.next.Reactions.Remove(ctx, target, targetID, userID, reaction)
+}
+
+func (s *reactionStore) Summaries(ctx context.Context, target store.ReactionTarget, targetIDs []int64, viewerID int64) (summaries map[int64]*store.ReactionSummary, err error) {
+	defer s.m.timeQuery("reactions", "Summaries")(&err)
+	return s.next.Reactions.Summaries(ctx, target, targetIDs, viewerID)
+}
+
+type blockStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *blockStore) Block(ctx context.Context, blockerID, blockedID int64) (err error) {
+	defer s.m.timeQuery("blocks", "Block")(&err)
+	return s.next.Blocks.Block(ctx, blockerID, blockedID)
+}
+
+func (s *blockStore) Unblock(ctx context.Context, blockerID, blockedID int64) (err error) {
+	defer s.m.timeQuery("blocks", "Unblock")(&err)
+	return s.next.Blocks.Unblock(ctx, blockerID, blockedID)
+}
+
+func (s *blockStore) IsBlocked(ctx context.Context, userID, otherID int64) (blocked bool, err error) {
+	defer s.m.timeQuery("blocks", "IsBlocked")(&err)
+	return s.next.Blocks.IsBlocked(ctx, userID, otherID)
+}
+
+func (s *blockStore) Mute(ctx context.Context, muterID, mutedID int64) (err error) {
+	defer s.m.timeQuery("blocks", "Mute")(&err)
+	return s.next.Blocks.Mute(ctx, muterID, mutedID)
+}
+
+func (s *blockStore) Unmute(ctx context.Context, muterID, mutedID int64) (err error) {
+	defer s.m.timeQuery("blocks", "Unmute")(&err)
+	return s.next.Blocks.Unmute(ctx, muterID, mutedID)
+}
+
+type trashStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *trashStore) List(ctx context.Context, userID int64, retention time.Duration, tq store.TrashQuery) (items []*store.TrashItem, err error) {
+	defer s.m.timeQuery("trash", "List")(&err)
+	return s.next.Trash.List(ctx, userID, retention, tq)
+}
+
+func (s *trashStore) Purge(ctx context.Context, retention time.Duration) (posts, comments int64, err error) {
+	defer s.m.timeQuery("trash", "Purge")(&err)
+	return s.next.Trash.Purge(ctx, retention)
+}
+
+type txStore struct {
+	next store.Storage
+	m    *Metrics
+}
+
+func (s *txStore) WithTx(ctx context.Context, fn func(store.Storage) error) error {
+	return s.next.Tx.WithTx(ctx, func(tx store.Storage) error {
+		return fn(s.m.InstrumentStorage(tx))
+	})
+}
@@ -0,0 +1,425 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// lockID is the key of the Postgres advisory lock held while migrating, so
+// that concurrent deploys apply migrations one at a time.
+const lockID int64 = 0x65776773636d6967 // "ewgscmig"
+
+var (
+	ErrDirty       = errors.New("database is dirty, fix it and run force")
+	ErrNoChange    = errors.New("no migrations to apply")
+	ErrNoMigration = errors.New("no migration with that version")
+
+	filenameRegexp = regexp.MustCompile(`^([0-9]+)_(.+)\.(up|down)\.sql$`)
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version    int64
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
+type MigrationStatus struct {
+	Version int64
+	Name    string
+	Applied bool
+}
+
+// Migrator applies migrations read from fsys. It keeps its bookkeeping in
+// the same schema_migrations table as golang-migrate, so databases migrated
+// with the migrate CLI carry on where they left off.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := load(fsys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+
+	for _, entry := range entries {
+		match := filenameRegexp.FindStringSubmatch(entry.Name())
+
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+
+		body, err := fs.ReadFile(fsys, entry.Name())
+
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has mismatched names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies up to n pending migrations, or all of them when n <= 0, and
+// returns how many were applied.
+func (m *Migrator) Up(ctx context.Context, n int) (int, error) {
+	applied := 0
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		if dirty {
+			return ErrDirty
+		}
+
+		for _, mig := range m.migrations {
+			if mig.Version <= version {
+				continue
+			}
+
+			if n > 0 && applied == n {
+				break
+			}
+
+			if err := m.apply(ctx, conn, mig.Up, mig.Version); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+
+			applied++
+		}
+
+		return nil
+	})
+
+	if err == nil && applied == 0 {
+		err = ErrNoChange
+	}
+
+	return applied, err
+}
+
+// Down rolls back the n most recently applied migrations and returns how many
+// were rolled back.
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("down needs a positive number of migrations, got %d", n)
+	}
+
+	rolledBack := 0
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		if dirty {
+			return ErrDirty
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && rolledBack < n; i-- {
+			mig := m.migrations[i]
+
+			if mig.Version > version {
+				continue
+			}
+
+			var prev int64
+
+			if i > 0 {
+				prev = m.migrations[i-1].Version
+			}
+
+			if err := m.apply(ctx, conn, mig.Down, prev); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+
+			rolledBack++
+		}
+
+		return nil
+	})
+
+	if err == nil && rolledBack == 0 {
+		err = ErrNoChange
+	}
+
+	return rolledBack, err
+}
+
+// Force records version as the current, clean version without running any
+// migration. It is the way out after a failed migration was fixed by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return ErrNoMigration
+	}
+
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+
+		if err != nil {
+			return err
+		}
+
+		if err := setVersion(ctx, tx, version, false); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// Version returns the current schema version and whether it is dirty.
+func (m *Migrator) Version(ctx context.Context) (int64, bool, error) {
+	conn, err := m.db.Conn(ctx)
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	defer conn.Close()
+
+	return m.version(ctx, conn)
+}
+
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	version, dirty, err := m.Version(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Version: version, Dirty: dirty}
+
+	for _, mig := range m.migrations {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version: mig.Version,
+			Name:    mig.Name,
+			Applied: mig.Version <= version,
+		})
+	}
+
+	return status, nil
+}
+
+// Latest returns the version of the newest known migration.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+
+	return nil
+}
+
+// apply runs one migration body and records the resulting version in the
+// same transaction, so a failing migration leaves the schema untouched.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, body string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := setVersion(ctx, tx, version, false); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// locked runs fn on a single connection holding the migration advisory lock.
+func (m *Migrator) locked(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+
+	// Unlock with a fresh context so a cancelled ctx doesn't leave the lock
+	// behind on a pooled connection.
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	query := `
+	  CREATE TABLE IF NOT EXISTS schema_migrations (
+	    version bigint NOT NULL PRIMARY KEY,
+	    dirty boolean NOT NULL
+	  )
+	`
+
+	_, err := conn.ExecContext(ctx, query)
+	return err
+}
+
+func (m *Migrator) version(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+
+	// Nothing has been migrated yet.
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "42P01" {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+// setVersion replaces the single schema_migrations row. Version 0 means no
+// migration is applied and is stored as an empty table.
+func setVersion(ctx context.Context, tx *sql.Tx, version int64, dirty bool) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+
+	if version == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty)
+	return err
+}
+
+// Create writes an empty, sequentially numbered up/down migration pair to dir
+// and returns the paths of the new files.
+func Create(dir, name string) ([]string, error) {
+	if name == "" {
+		return nil, errors.New("migration name is required")
+	}
+
+	migrations, err := load(os.DirFS(dir))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var next int64 = 1
+
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+
+	var paths []string
+
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", next, name, direction))
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+
+		if err != nil {
+			return paths, err
+		}
+
+		if err := f.Close(); err != nil {
+			return paths, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
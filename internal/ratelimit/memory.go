@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore drops expired entries.
+const sweepInterval = time.Minute
+
+type entry struct {
+	count   int64
+	tokens  float64
+	last    time.Time
+	expires time.Time
+}
+
+// MemoryStore keeps limiter state in process. It is the default store, and
+// only suitable when a single API instance is running.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: map[string]*entry{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	e, ok := s.entries[key]
+
+	if !ok || !now.Before(e.expires) {
+		e = &entry{expires: now.Add(window)}
+		s.entries[key] = e
+	}
+
+	e.count++
+
+	return e.count, e.expires.Sub(now), nil
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	e, ok := s.entries[key]
+
+	if !ok {
+		e = &entry{tokens: float64(burst), last: now}
+		s.entries[key] = e
+	}
+
+	e.tokens = math.Min(float64(burst), e.tokens+now.Sub(e.last).Seconds()*rate)
+	e.last = now
+
+	// A full bucket is indistinguishable from no bucket, so the entry can go
+	// once it would have refilled.
+	e.expires = now.Add(time.Duration(float64(burst) / rate * float64(time.Second)))
+
+	if e.tokens < 1 {
+		wait := time.Duration((1 - e.tokens) / rate * float64(time.Second))
+		return false, wait, nil
+	}
+
+	e.tokens--
+
+	return true, 0, nil
+}
+
+// sweep drops expired entries so that one-off clients don't pile up.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+
+	s.lastSweep = now
+
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Result is the outcome of a single Allow call. RetryAfter is only set when
+// the request was rejected.
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// Store keeps limiter state. Implementations must apply each call
+// atomically, since several API instances may share one store.
+type Store interface {
+	// Incr counts a hit in key's current window, starting a new window of
+	// the given length when there is none, and returns the count so far and
+	// the time left until the window resets.
+	Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+
+	// Take removes a token from key's bucket, which refills at rate tokens
+	// per second up to burst. When the bucket is empty it returns false and
+	// how long until the next token arrives.
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+}
+
+// FixedWindow allows Limit requests per key in every Window.
+type FixedWindow struct {
+	Store  Store
+	Prefix string
+	Limit  int
+	Window time.Duration
+}
+
+func (l *FixedWindow) Allow(ctx context.Context, key string) (Result, error) {
+	count, resetIn, err := l.Store.Incr(ctx, l.Prefix+key, l.Window)
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	if count > int64(l.Limit) {
+		return Result{RetryAfter: resetIn}, nil
+	}
+
+	return Result{Allowed: true}, nil
+}
+
+// TokenBucket allows bursts of up to Burst requests per key and then Rate
+// requests per second.
+type TokenBucket struct {
+	Store  Store
+	Prefix string
+	Rate   float64
+	Burst  int
+}
+
+func (l *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	ok, wait, err := l.Store.Take(ctx, l.Prefix+key, l.Rate, l.Burst)
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	if !ok {
+		return Result{RetryAfter: wait}, nil
+	}
+
+	return Result{Allowed: true}, nil
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testStore is a Store whose clock the test controls.
+type testStore struct {
+	Store
+	advance func(d time.Duration)
+}
+
+func newTestStores(t *testing.T) map[string]testStore {
+	t.Helper()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	memory := NewMemoryStore()
+	memoryNow := start
+	memory.now = func() time.Time { return memoryNow }
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	rs := NewRedisStore(rdb)
+	redisNow := start
+	rs.now = func() time.Time { return redisNow }
+
+	return map[string]testStore{
+		"memory": {Store: memory, advance: func(d time.Duration) { memoryNow = memoryNow.Add(d) }},
+		"redis": {Store: rs, advance: func(d time.Duration) {
+			redisNow = redisNow.Add(d)
+			mr.FastForward(d)
+		}},
+	}
+}
+
+// allow calls l.Allow n times and returns how many calls were allowed.
+func allow(t *testing.T, l Limiter, key string, n int) int {
+	t.Helper()
+
+	allowed := 0
+
+	for range n {
+		res, err := l.Allow(context.Background(), key)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Allowed {
+			allowed++
+		} else if res.RetryAfter <= 0 {
+			t.Fatalf("rejected with RetryAfter %v", res.RetryAfter)
+		}
+	}
+
+	return allowed
+}
+
+func TestFixedWindow(t *testing.T) {
+	for name, s := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			l := &FixedWindow{Store: s, Prefix: "fw:", Limit: 3, Window: time.Minute}
+
+			if got := allow(t, l, "a", 5); got != 3 {
+				t.Errorf("first window: allowed %d, want 3", got)
+			}
+
+			// Keys are counted separately.
+			if got := allow(t, l, "b", 1); got != 1 {
+				t.Errorf("other key: allowed %d, want 1", got)
+			}
+
+			s.advance(30 * time.Second)
+
+			if got := allow(t, l, "a", 1); got != 0 {
+				t.Errorf("same window: allowed %d, want 0", got)
+			}
+
+			s.advance(31 * time.Second)
+
+			if got := allow(t, l, "a", 5); got != 3 {
+				t.Errorf("next window: allowed %d, want 3", got)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, s := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			l := &TokenBucket{Store: s, Prefix: "tb:", Rate: 2, Burst: 4}
+
+			if got := allow(t, l, "a", 6); got != 4 {
+				t.Errorf("burst: allowed %d, want 4", got)
+			}
+
+			if got := allow(t, l, "b", 1); got != 1 {
+				t.Errorf("other key: allowed %d, want 1", got)
+			}
+
+			// Two tokens per second come back.
+			s.advance(time.Second)
+
+			if got := allow(t, l, "a", 3); got != 2 {
+				t.Errorf("after a second: allowed %d, want 2", got)
+			}
+
+			// The bucket never holds more than the burst.
+			s.advance(time.Hour)
+
+			if got := allow(t, l, "a", 6); got != 4 {
+				t.Errorf("after an hour: allowed %d, want 4", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Both scripts only use basic commands, so they also run against Redis
+// stand-ins such as miniredis or KeyDB.
+var (
+	incrScript = redis.NewScript(`
+		local count = redis.call('INCR', KEYS[1])
+
+		if count == 1 then
+		  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+		end
+
+		return {count, redis.call('PTTL', KEYS[1])}
+	`)
+
+	// takeScript gets the current time from the caller rather than from
+	// TIME, which keeps the script deterministic for replication.
+	takeScript = redis.NewScript(`
+		local rate = tonumber(ARGV[1])
+		local burst = tonumber(ARGV[2])
+		local now = tonumber(ARGV[3])
+
+		local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+		local tokens = tonumber(state[1]) or burst
+		local last = tonumber(state[2]) or now
+
+		tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+
+		local allowed, wait = 0, 0
+
+		if tokens >= 1 then
+		  tokens = tokens - 1
+		  allowed = 1
+		else
+		  wait = math.ceil((1 - tokens) / rate)
+		end
+
+		redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+		redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate))
+
+		return {allowed, wait}
+	`)
+)
+
+// RedisStore keeps limiter state in Redis so that every API instance shares
+// the same limits.
+type RedisStore struct {
+	client redis.Scripter
+	now    func() time.Time
+}
+
+func NewRedisStore(client redis.Scripter) *RedisStore {
+	return &RedisStore{client: client, now: time.Now}
+}
+
+func (s *RedisStore) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	res, err := incrScript.Run(ctx, s.client, []string{key}, window.Milliseconds()).Int64Slice()
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return res[0], time.Duration(res[1]) * time.Millisecond, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	// The script works in milliseconds.
+	perMilli := rate / 1000
+	now := s.now().UnixMilli()
+
+	res, err := takeScript.Run(ctx, s.client, []string{key}, perMilli, burst, now).Int64Slice()
+
+	if err != nil {
+		return false, 0, err
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
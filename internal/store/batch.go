@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// batchSize caps the rows per INSERT statement, keeping each statement well
+// under Postgres' limit of 65535 bind parameters.
+const batchSize = 500
+
+// batchInsert inserts rows into table with one multi-row INSERT per
+// batchSize rows. Every row must have one value per column.
+func batchInsert(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]any, suffix string) error {
+	for start := 0; start < len(rows); start += batchSize {
+		end := min(start+batchSize, len(rows))
+
+		var (
+			values []string
+			args   []any
+		)
+
+		for _, row := range rows[start:end] {
+			placeholders := make([]string, len(row))
+
+			for i := range row {
+				args = append(args, row[i])
+				placeholders[i] = fmt.Sprintf("$%d", len(args))
+			}
+
+			values = append(values, "("+strings.Join(placeholders, ", ")+")")
+		}
+
+		query := fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES %s %s",
+			table,
+			strings.Join(columns, ", "),
+			strings.Join(values, ", "),
+			suffix,
+		)
+
+		ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+		_, err := tx.ExecContext(ctx, query, args...)
+		cancel()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserveIDs draws n ids from a serial column's sequence up front, so batch
+// inserts can hand ids back to callers without relying on RETURNING order.
+func reserveIDs(ctx context.Context, tx *sql.Tx, sequence string, n int) ([]int64, error) {
+	query := `SELECT array_agg(nextval($1::regclass)) FROM generate_series(1, $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	var ids []int64
+
+	if err := tx.QueryRowContext(ctx, query, sequence, n).Scan(pq.Array(&ids)); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// timeOrNow turns the string timestamps used by the models into a column
+// value, defaulting to the current time when t is empty.
+func timeOrNow(t string) any {
+	if t == "" {
+		return time.Now()
+	}
+
+	return t
+}
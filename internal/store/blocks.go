@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var ErrBlocked = errors.New("one of the users has blocked the other")
+
+// blockedBetween is true when either of the two users blocked the other.
+// Blocks hide content both ways, so every check goes in both directions.
+const blockedBetween = `EXISTS (
+	SELECT 1 FROM user_blocks b
+	WHERE (b.blocker_id = %[1]s AND b.blocked_id = %[2]s)
+	   OR (b.blocker_id = %[2]s AND b.blocked_id = %[1]s)
+)`
+
+type BlockStore struct {
+	db DBTX
+}
+
+// Block makes blockerID block blockedID and drops the follows and follow
+// requests between them, in both directions. Blocking someone twice is a
+// no-op.
+func (s *BlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+		defer cancel()
+
+		query := `
+		  INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2)
+		  ON CONFLICT DO NOTHING
+		`
+
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrNotFound
+			}
+
+			return err
+		}
+
+		query = `
+		  DELETE FROM followers
+		  WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		`
+
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		query = `
+		  DELETE FROM follow_requests
+		  WHERE (requester_id = $1 AND target_id = $2) OR (requester_id = $2 AND target_id = $1)
+		`
+
+		_, err := tx.ExecContext(ctx, query, blockerID, blockedID)
+		return err
+	})
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+
+	_, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	return err
+}
+
+// IsBlocked reports whether either user blocked the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	query := `SELECT ` + fmt.Sprintf(blockedBetween, "$1", "$2")
+
+	var blocked bool
+
+	err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked)
+	return blocked, err
+}
+
+// Mute hides mutedID's posts from muterID's feed. Unlike a block it is
+// invisible to the muted user and leaves follows alone.
+func (s *BlockStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	query := `
+	  INSERT INTO user_mutes (muter_id, muted_id) VALUES ($1, $2)
+	  ON CONFLICT DO NOTHING
+	`
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (s *BlockStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	query := `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
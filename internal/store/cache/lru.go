@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+// NewLRUStorage keeps up to size users and size posts in process, each for at
+// most ttl. Like the in-memory rate limiter it is only coherent with a single
+// API instance.
+func NewLRUStorage(size int, ttl time.Duration) Storage {
+	return Storage{
+		Users: &UserLRU{newLRU[store.User](size, ttl)},
+		Posts: &PostLRU{newLRU[store.Post](size, ttl)},
+	}
+}
+
+type UserLRU struct {
+	lru *lru[store.User]
+}
+
+func (c *UserLRU) Get(ctx context.Context, userID int64) (*store.User, error) {
+	return c.lru.get(userID), nil
+}
+
+func (c *UserLRU) Set(ctx context.Context, user *store.User) error {
+	c.lru.set(user.ID, *user)
+	return nil
+}
+
+func (c *UserLRU) Delete(ctx context.Context, userID int64) error {
+	c.lru.delete(userID)
+	return nil
+}
+
+type PostLRU struct {
+	lru *lru[store.Post]
+}
+
+func (c *PostLRU) Get(ctx context.Context, postID int64) (*store.Post, error) {
+	return c.lru.get(postID), nil
+}
+
+func (c *PostLRU) Set(ctx context.Context, post *store.Post) error {
+	c.lru.set(post.ID, *post)
+	return nil
+}
+
+func (c *PostLRU) Delete(ctx context.Context, postID int64) error {
+	c.lru.delete(postID)
+	return nil
+}
+
+type lruEntry[V any] struct {
+	key     int64
+	value   V
+	expires time.Time
+}
+
+// lru stores values rather than pointers and hands out copies, since
+// handlers modify the models they get from context.
+type lru[V any] struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List
+	items map[int64]*list.Element
+	now   func() time.Time
+}
+
+func newLRU[V any](size int, ttl time.Duration) *lru[V] {
+	return &lru[V]{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: map[int64]*list.Element{},
+		now:   time.Now,
+	}
+}
+
+func (c *lru[V]) get(key int64) *V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+
+	if !ok {
+		return nil
+	}
+
+	entry := el.Value.(*lruEntry[V])
+
+	if c.now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil
+	}
+
+	c.order.MoveToFront(el)
+	value := entry.value
+
+	return &value
+}
+
+func (c *lru[V]) set(key int64, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+
+	if el, ok := c.items[key]; ok {
+		el.Value = &lruEntry[V]{key: key, value: value, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, expires: expires})
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *lru[V]) delete(key int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
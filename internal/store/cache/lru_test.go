@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// testLRU returns an lru of ints whose clock advance moves forward.
+func testLRU(size int, ttl time.Duration) (c *lru[int], advance func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c = newLRU[int](size, ttl)
+	c.now = func() time.Time { return now }
+
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+// cached returns the keys in 1..10 that c holds.
+func cached(c *lru[int]) []int64 {
+	var keys []int64
+
+	for key := int64(1); key <= 10; key++ {
+		if c.get(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+func TestLRU(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(c *lru[int], advance func(time.Duration))
+		want []int64
+	}{
+		{
+			name: "evicts the least recently set",
+			run: func(c *lru[int], advance func(time.Duration)) {
+				c.set(1, 1)
+				c.set(2, 2)
+				c.set(3, 3)
+				c.set(4, 4)
+			},
+			want: []int64{2, 3, 4},
+		},
+		{
+			name: "reads count as use",
+			run: func(c *lru[int], advance func(time.Duration)) {
+				c.set(1, 1)
+				c.set(2, 2)
+				c.set(3, 3)
+				c.get(1)
+				c.set(4, 4)
+			},
+			want: []int64{1, 3, 4},
+		},
+		{
+			name: "overwrites count as use",
+			run: func(c *lru[int], advance func(time.Duration)) {
+				c.set(1, 1)
+				c.set(2, 2)
+				c.set(3, 3)
+				c.set(1, 10)
+				c.set(4, 4)
+			},
+			want: []int64{1, 3, 4},
+		},
+		{
+			name: "expires after the ttl",
+			run: func(c *lru[int], advance func(time.Duration)) {
+				c.set(1, 1)
+				advance(30 * time.Second)
+				c.set(2, 2)
+				advance(31 * time.Second)
+			},
+			want: []int64{2},
+		},
+		{
+			name: "overwrites restart the ttl",
+			run: func(c *lru[int], advance func(time.Duration)) {
+				c.set(1, 1)
+				advance(30 * time.Second)
+				c.set(1, 10)
+				advance(31 * time.Second)
+			},
+			want: []int64{1},
+		},
+		{
+			name: "delete invalidates",
+			run: func(c *lru[int], advance func(time.Duration)) {
+				c.set(1, 1)
+				c.set(2, 2)
+				c.delete(1)
+				c.delete(5)
+			},
+			want: []int64{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, advance := testLRU(3, time.Minute)
+			tt.run(c, advance)
+
+			if got := cached(c); !slices.Equal(got, tt.want) {
+				t.Errorf("got keys %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLRUHandsOutCopies(t *testing.T) {
+	c, _ := testLRU(3, time.Minute)
+	c.set(1, 1)
+
+	*c.get(1) = 2
+
+	if got := *c.get(1); got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+
+	c.set(1, 3)
+
+	if got := *c.get(1); got != 3 {
+		t.Errorf("after an update: got %d, want 3", got)
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// NewRedisStorage caches users and posts in Redis as JSON, so every API
+// instance sees the same entries. Password hashes are not cached, as
+// store.User doesn't serialize them.
+func NewRedisStorage(client redis.Cmdable, ttl time.Duration) Storage {
+	return Storage{
+		Users: &UserRedis{redisCache[store.User]{client: client, prefix: "user", ttl: ttl}},
+		Posts: &PostRedis{redisCache[store.Post]{client: client, prefix: "post", ttl: ttl}},
+	}
+}
+
+type UserRedis struct {
+	cache redisCache[store.User]
+}
+
+func (c *UserRedis) Get(ctx context.Context, userID int64) (*store.User, error) {
+	return c.cache.get(ctx, userID)
+}
+
+func (c *UserRedis) Set(ctx context.Context, user *store.User) error {
+	return c.cache.set(ctx, user.ID, user)
+}
+
+func (c *UserRedis) Delete(ctx context.Context, userID int64) error {
+	return c.cache.delete(ctx, userID)
+}
+
+type PostRedis struct {
+	cache redisCache[store.Post]
+}
+
+func (c *PostRedis) Get(ctx context.Context, postID int64) (*store.Post, error) {
+	return c.cache.get(ctx, postID)
+}
+
+func (c *PostRedis) Set(ctx context.Context, post *store.Post) error {
+	return c.cache.set(ctx, post.ID, post)
+}
+
+func (c *PostRedis) Delete(ctx context.Context, postID int64) error {
+	return c.cache.delete(ctx, postID)
+}
+
+type redisCache[V any] struct {
+	client redis.Cmdable
+	prefix string
+	ttl    time.Duration
+}
+
+func (c redisCache[V]) key(id int64) string {
+	return fmt.Sprintf("%s-%d", c.prefix, id)
+}
+
+func (c redisCache[V]) get(ctx context.Context, id int64) (*V, error) {
+	data, err := c.client.Get(ctx, c.key(id)).Bytes()
+
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var value V
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (c redisCache[V]) set(ctx context.Context, id int64, value *V) error {
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return err
+	}
+
+	return c.client.Set(ctx, c.key(id), data, c.ttl).Err()
+}
+
+func (c redisCache[V]) delete(ctx context.Context, id int64) error {
+	return c.client.Del(ctx, c.key(id)).Err()
+}
@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+// Storage caches the hot single-row lookups of store.Storage. Get returns
+// nil and no error on a miss; callers read through to the store and Set the
+// result. Callers must not assume the cached value shares memory with
+// anything they hold.
+type Storage struct {
+	Users UsersCache
+	Posts PostsCache
+}
+
+type UsersCache interface {
+	Get(ctx context.Context, userID int64) (*store.User, error)
+	Set(ctx context.Context, user *store.User) error
+	Delete(ctx context.Context, userID int64) error
+}
+
+type PostsCache interface {
+	Get(ctx context.Context, postID int64) (*store.Post, error)
+	Set(ctx context.Context, post *store.Post) error
+	Delete(ctx context.Context, postID int64) error
+}
@@ -3,33 +3,62 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 )
 
 type CommentStore struct {
-	db *sql.DB
+	db DBTX
 }
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	User      User   `json:"user"`
-	Content   string `json:"content"`
-	CreatedAt int64  `json:"created_at"`
+	ID        int64      `json:"id"`
+	PostID    int64      `json:"post_id"`
+	UserID    int64      `json:"user_id"`
+	ParentID  *int64     `json:"parent_id"`
+	User      User       `json:"user"`
+	Content   string     `json:"content"`
+	Depth     int        `json:"depth"`
+	Replies   []*Comment `json:"replies,omitempty"`
+	CreatedAt string     `json:"created_at"`
+	UpdatedAt string     `json:"updated_at"`
+
+	Reactions *ReactionSummary `json:"reactions,omitempty"`
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) (*[]Comment, error) {
+// GetByPostID returns the comments of a post as a flat list in thread order:
+// top-level comments newest first, each followed by its replies oldest first.
+// Depth is 0 for top-level comments. Comments of users viewerID blocked, or
+// was blocked by, are left out; replies to them are kept. Trashed comments
+// are left out with their replies.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) (*[]Comment, error) {
+	// Roots get a negated id as the first path element so that ordering by
+	// path puts newer threads first while keeping replies chronological.
 	query := `
-	  SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.username, u.id FROM comments c
-	  JOIN users u on u.id = c.user_id
-	  WHERE c.post_id = $1
-	  ORDER BY c.created_at DESC;
+	  WITH RECURSIVE thread AS (
+	    SELECT id, 0 AS depth, ARRAY[-id] AS path
+	    FROM comments
+	    WHERE post_id = $1 AND parent_id IS NULL AND deleted_at IS NULL
+	    UNION ALL
+	    SELECT c.id, t.depth + 1, t.path || c.id
+	    FROM comments c
+	    JOIN thread t ON c.parent_id = t.id
+	    WHERE c.deleted_at IS NULL
+	  )
+	  SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.created_at, c.updated_at,
+	    t.depth, u.username, u.id
+	  FROM thread t
+	  JOIN comments c ON c.id = t.id
+	  JOIN users u ON u.id = c.user_id
+	  WHERE NOT ` + fmt.Sprintf(blockedBetween, "$2::bigint", "c.user_id") + `
+	  ORDER BY t.path;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 
 	if err != nil {
 		return nil, err
@@ -43,7 +72,18 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) (*[]Commen
 		var c Comment
 
 		c.User = User{}
-		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.User.Username, &c.User.ID, &c.CreatedAt)
+		err := rows.Scan(
+			&c.ID,
+			&c.PostID,
+			&c.UserID,
+			&c.ParentID,
+			&c.Content,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+			&c.Depth,
+			&c.User.Username,
+			&c.User.ID,
+		)
 
 		if err != nil {
 			return nil, err
@@ -52,5 +92,236 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) (*[]Commen
 		comments = append(comments, c)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return &comments, nil
 }
+
+func (s *CommentStore) GetByID(ctx context.Context, commentID int64) (*Comment, error) {
+	query := `
+	  SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.created_at, c.updated_at,
+	    u.username, u.id
+	  FROM comments c
+	  JOIN users u ON u.id = c.user_id
+	  WHERE c.id = $1 AND c.deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	var c Comment
+
+	err := s.db.QueryRowContext(ctx, query, commentID).Scan(
+		&c.ID,
+		&c.PostID,
+		&c.UserID,
+		&c.ParentID,
+		&c.Content,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.User.Username,
+		&c.User.ID,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
+	query := `
+	  INSERT INTO comments (post_id, user_id, parent_id, content)
+	  VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		comment.PostID,
+		comment.UserID,
+		comment.ParentID,
+		comment.Content,
+	).Scan(
+		&comment.ID,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+	)
+}
+
+// CreateBatch inserts many comments in one transaction. It is meant for
+// seeding and, unlike Create, honours CreatedAt. Parents must come before
+// their replies.
+func (s *CommentStore) CreateBatch(ctx context.Context, comments []*Comment) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		ids, err := reserveIDs(ctx, tx, "comments_id_seq", len(comments))
+
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]any, len(comments))
+
+		for i, comment := range comments {
+			comment.ID = ids[i]
+			createdAt := timeOrNow(comment.CreatedAt)
+			rows[i] = []any{comment.ID, comment.PostID, comment.UserID, comment.ParentID, comment.Content, createdAt, createdAt}
+		}
+
+		columns := []string{"id", "post_id", "user_id", "parent_id", "content", "created_at", "updated_at"}
+
+		return batchInsert(ctx, tx, "comments", columns, rows, "")
+	})
+}
+
+func (s *CommentStore) UpdateByID(ctx context.Context, comment *Comment) error {
+	query := `
+	  UPDATE comments
+	  SET content = $1, updated_at = NOW()
+	  WHERE id = $2 AND deleted_at IS NULL
+	  RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, comment.Content, comment.ID).Scan(&comment.UpdatedAt)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteByID moves a comment and its replies to their authors' trash,
+// recording that deletedBy deleted them.
+// They share one deleted_at, which is how Restore and the trash tell the
+// replies that went with their parent from ones deleted on their own.
+func (s *CommentStore) DeleteByID(ctx context.Context, commentID, deletedBy int64) error {
+	query := `
+	  WITH RECURSIVE tree AS (
+	    SELECT id FROM comments WHERE id = $1 AND deleted_at IS NULL
+	    UNION ALL
+	    SELECT c.id
+	    FROM comments c
+	    JOIN tree t ON c.parent_id = t.id
+	    WHERE c.deleted_at IS NULL
+	  )
+	  UPDATE comments SET deleted_at = NOW(), deleted_by = $2
+	  WHERE id IN (SELECT id FROM tree)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, commentID, deletedBy)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Restore takes a comment of postID deleted less than retention ago out of
+// the trash, along with the replies deleted with it. userID must be its
+// author or whoever deleted it. Replies that went with their parent can only
+// come back with it.
+func (s *CommentStore) Restore(ctx context.Context, postID, commentID, userID int64, retention time.Duration) error {
+	query := `
+	  WITH RECURSIVE tree AS (
+	    SELECT id, deleted_at
+	    FROM comments c
+	    WHERE id = $1 AND post_id = $2 AND (user_id = $3 OR deleted_by = $3)
+	      AND ` + fmt.Sprintf(inRetention, "deleted_at", "$4") + `
+	      AND NOT ` + fmt.Sprintf(deletedWithParent, "c") + `
+	    UNION ALL
+	    SELECT c.id, c.deleted_at
+	    FROM comments c
+	    JOIN tree t ON c.parent_id = t.id AND c.deleted_at = t.deleted_at
+	  )
+	  UPDATE comments SET deleted_at = NULL, deleted_by = NULL
+	  WHERE id IN (SELECT id FROM tree)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, commentID, postID, userID, retention.Seconds())
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// BuildCommentTree nests a flat, thread-ordered list as returned by
+// GetByPostID into top-level comments with their replies.
+func BuildCommentTree(comments []Comment) []*Comment {
+	nodes := make(map[int64]*Comment, len(comments))
+	roots := []*Comment{}
+
+	for i := range comments {
+		c := comments[i]
+		c.Replies = nil
+		nodes[c.ID] = &c
+	}
+
+	for i := range comments {
+		node := nodes[comments[i].ID]
+
+		if node.ParentID == nil {
+			roots = append(roots, node)
+			continue
+		}
+
+		parent, ok := nodes[*node.ParentID]
+
+		if !ok {
+			roots = append(roots, node)
+			continue
+		}
+
+		parent.Replies = append(parent.Replies, node)
+	}
+
+	return roots
+}
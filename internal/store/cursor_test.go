@@ -0,0 +1,108 @@
+package store
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorSignerRoundTrip(t *testing.T) {
+	s := NewCursorSigner("secret")
+
+	want := Cursor{
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		ID:        42,
+		Direction: CursorNext,
+		Sort:      "desc",
+		Scope:     "feed:abc",
+	}
+
+	token, err := s.Encode(want)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Decode(token)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID || got.Direction != want.Direction ||
+		got.Sort != want.Sort || got.Scope != want.Scope {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+}
+
+func TestCursorSignerRejects(t *testing.T) {
+	s := NewCursorSigner("secret")
+	enc := base64.RawURLEncoding
+
+	encode := func(c Cursor) string {
+		token, err := s.Encode(c)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return token
+	}
+
+	valid := Cursor{ID: 1, Direction: CursorNext, Sort: "asc", Scope: "feed:abc"}
+	token := encode(valid)
+	payload, sig, _ := strings.Cut(token, ".")
+
+	forged, err := NewCursorSigner("other secret").Encode(valid)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no signature", payload},
+		{"bad base64", payload + ".!!"},
+		{"signed with another secret", forged},
+		{"tampered payload", enc.EncodeToString([]byte(`{"i":2,"d":"next","s":"asc","sc":"feed:abc"}`)) + "." + sig},
+		{"truncated signature", payload + "." + sig[:len(sig)-2]},
+		{"bad direction", encode(Cursor{ID: 1, Direction: "sideways", Sort: "asc"})},
+		{"bad sort", encode(Cursor{ID: 1, Direction: CursorNext, Sort: "random"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Decode(tt.token); err != ErrInvalidCursor {
+				t.Errorf("got error %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestCursorKeyset(t *testing.T) {
+	tests := []struct {
+		sort, direction string
+		op, order       string
+		reverse         bool
+	}{
+		{"asc", CursorNext, ">", "ASC", false},
+		{"asc", CursorPrev, "<", "DESC", true},
+		{"desc", CursorNext, "<", "DESC", false},
+		{"desc", CursorPrev, ">", "ASC", true},
+	}
+
+	for _, tt := range tests {
+		c := &Cursor{Sort: tt.sort, Direction: tt.direction}
+
+		op, order, reverse := c.keyset()
+
+		if op != tt.op || order != tt.order || reverse != tt.reverse {
+			t.Errorf("%s %s: got (%s, %s, %t), want (%s, %s, %t)",
+				tt.sort, tt.direction, op, order, reverse, tt.op, tt.order, tt.reverse)
+		}
+	}
+}
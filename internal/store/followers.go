@@ -3,46 +3,405 @@ package store
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
 
 	"github.com/lib/pq"
 )
 
+// A row of followers reads "user_id follows follower_id": user_id is the
+// follower and follower_id the one being followed.
+
+const (
+	FollowersList      = "followers"
+	FollowingList      = "following"
+	MutualsList        = "mutuals"
+	FollowRequestsList = "requests"
+)
+
 type Follower struct {
 	UserID     int64  `json:"user_id"`
 	FollowerID int64  `json:"follower_id"`
 	CreatedAt  string `json:"created_at"`
 }
 
+// FollowListEntry is a user in someone's followers, following, mutuals or
+// pending follow requests list. Since is when the follow, or for mutuals the
+// later of the two follows, happened. IsFollowing and FollowsYou are relative to the viewer.
+type FollowListEntry struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	Since       string `json:"since"`
+	IsFollowing bool   `json:"is_following"`
+	FollowsYou  bool   `json:"follows_you"`
+}
+
+// FollowStats are the follow counts of a user and how they relate to the
+// viewer.
+type FollowStats struct {
+	FollowersCount int  `json:"followers_count"`
+	FollowingCount int  `json:"following_count"`
+	IsFollowing    bool `json:"is_following"`
+	FollowsYou     bool `json:"follows_you"`
+}
+
+// FollowListQuery pages through a follow list newest first, the same way
+// FeedPaginationQuery pages through the feed.
+type FollowListQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Cursor string `json:"cursor" validate:"omitempty,max=512"`
+
+	// Position is the decoded Cursor. When it is nil the list falls back to
+	// LIMIT/OFFSET pagination.
+	Position *Cursor `json:"-"`
+}
+
+func (fq FollowListQuery) Parse(r *http.Request) (FollowListQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return fq, &ParamError{Param: "limit", Value: limit, Expected: "an integer"}
+		}
+
+		fq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+
+		if err != nil {
+			return fq, &ParamError{Param: "offset", Value: offset, Expected: "an integer"}
+		}
+
+		fq.Offset = o
+	}
+
+	if cursor := qs.Get("cursor"); cursor != "" {
+		fq.Cursor = cursor
+	}
+
+	return fq, nil
+}
+
 type FollowerStore struct {
-	db *sql.DB
+	db DBTX
 }
 
-func (store *FollowerStore) Follow(ctx context.Context, followedID, userID int64) error {
-	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
-	defer cancel()
+// Follow makes userID follow followedID or, when followedID is a private
+// account, leaves a follow request with it, and reports which it did. The
+// users row is read FOR SHARE in the same transaction, so that the account
+// cannot turn private or public between the check and the insert.
+func (store *FollowerStore) Follow(ctx context.Context, followedID, userID int64) (requested bool, err error) {
+	err = withTx(ctx, store.db, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+		defer cancel()
 
-	query := `
-	  INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)
-	`
+		query := `
+		  SELECT is_private FROM users WHERE id = $1 FOR SHARE
+		`
+
+		if err := tx.QueryRowContext(ctx, query, followedID).Scan(&requested); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if requested {
+			return requestFollow(ctx, tx, followedID, userID)
+		}
 
-	_, err := store.db.ExecContext(ctx, query, userID, followedID)
+		query = `
+		  INSERT INTO followers (user_id, follower_id)
+		  SELECT $1::bigint, $2::bigint
+		  WHERE NOT ` + fmt.Sprintf(blockedBetween, "$1", "$2")
+
+		return insertFollow(ctx, tx, query, userID, followedID)
+	})
+
+	return requested, err
+}
+
+// insertFollow runs an INSERT guarded by blockedBetween, telling a duplicate
+// row apart from a block.
+func insertFollow(ctx context.Context, tx *sql.Tx, query string, args ...any) error {
+	res, err := tx.ExecContext(ctx, query, args...)
 
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
 			return ErrConflict
 		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrBlocked
 	}
-	return err
+
+	return nil
 }
 
+// Unfollow also withdraws a pending follow request.
 func (store *FollowerStore) Unfollow(ctx context.Context, unfollowedID, userID int64) error {
+	return withTx(ctx, store.db, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+		defer cancel()
+
+		query := `
+		  DELETE FROM followers WHERE user_id = $1 AND follower_id = $2
+		`
+
+		if _, err := tx.ExecContext(ctx, query, userID, unfollowedID); err != nil {
+			return err
+		}
+
+		query = `
+		  DELETE FROM follow_requests WHERE requester_id = $1 AND target_id = $2
+		`
+
+		_, err := tx.ExecContext(ctx, query, userID, unfollowedID)
+		return err
+	})
+}
+
+func (store *FollowerStore) IsFollowing(ctx context.Context, userID, targetID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	query := `
+	  SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
+	`
+
+	var following bool
+
+	err := store.db.QueryRowContext(ctx, query, userID, targetID).Scan(&following)
+	return following, err
+}
+
+// requestFollow asks the private account targetID to let userID follow it.
+// It fails with ErrConflict when userID already follows targetID or asked
+// before.
+func requestFollow(ctx context.Context, tx *sql.Tx, targetID, userID int64) error {
+	query := `
+	  SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
+	`
+
+	var following bool
+
+	if err := tx.QueryRowContext(ctx, query, userID, targetID).Scan(&following); err != nil {
+		return err
+	}
+
+	if following {
+		return ErrConflict
+	}
+
+	query = `
+	  INSERT INTO follow_requests (requester_id, target_id)
+	  SELECT $1::bigint, $2::bigint
+	  WHERE NOT ` + fmt.Sprintf(blockedBetween, "$1", "$2")
+
+	return insertFollow(ctx, tx, query, userID, targetID)
+}
+
+// Approve turns requesterID's pending request into a follow of targetID.
+func (store *FollowerStore) Approve(ctx context.Context, targetID, requesterID int64) error {
+	return withTx(ctx, store.db, func(tx *sql.Tx) error {
+		if err := deleteFollowRequest(ctx, tx, targetID, requesterID); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+		defer cancel()
+
+		query := `
+		  INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)
+		  ON CONFLICT DO NOTHING
+		`
+
+		_, err := tx.ExecContext(ctx, query, requesterID, targetID)
+		return err
+	})
+}
+
+func (store *FollowerStore) Reject(ctx context.Context, targetID, requesterID int64) error {
+	return deleteFollowRequest(ctx, store.db, targetID, requesterID)
+}
+
+func deleteFollowRequest(ctx context.Context, db DBTX, targetID, requesterID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	query := `
+	  DELETE FROM follow_requests WHERE requester_id = $1 AND target_id = $2
+	`
+
+	res, err := db.ExecContext(ctx, query, requesterID, targetID)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// FollowBatch inserts many follow edges in one transaction, skipping edges
+// that already exist. It is meant for seeding.
+func (store *FollowerStore) FollowBatch(ctx context.Context, follows []Follower) error {
+	return withTx(ctx, store.db, func(tx *sql.Tx) error {
+		rows := make([][]any, len(follows))
+
+		for i, f := range follows {
+			rows[i] = []any{f.UserID, f.FollowerID}
+		}
+
+		return batchInsert(ctx, tx, "followers", []string{"user_id", "follower_id"}, rows, "ON CONFLICT DO NOTHING")
+	})
+}
+
+// Each query selects the id of a user on the list of $1 and since.
+var followListQueries = map[string]string{
+	FollowersList: `
+		SELECT f.user_id AS id, f.created_at AS since
+		FROM followers f
+		WHERE f.follower_id = $1`,
+	FollowingList: `
+		SELECT f.follower_id AS id, f.created_at AS since
+		FROM followers f
+		WHERE f.user_id = $1`,
+	MutualsList: `
+		SELECT a.follower_id AS id, GREATEST(a.created_at, b.created_at) AS since
+		FROM followers a
+		JOIN followers b ON b.user_id = a.follower_id AND b.follower_id = a.user_id
+		WHERE a.user_id = $1`,
+	FollowRequestsList: `
+		SELECT r.requester_id AS id, r.created_at AS since
+		FROM follow_requests r
+		WHERE r.target_id = $1`,
+}
+
+// List returns a page of userID's followers, following or mutuals list, as
+// seen by viewerID.
+func (store *FollowerStore) List(ctx context.Context, list string, userID, viewerID int64, fq FollowListQuery) ([]*FollowListEntry, error) {
+	inner, ok := followListQueries[list]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown follow list %q", list)
+	}
+
+	args := []any{userID, viewerID, fq.Limit}
+	where := "TRUE"
+	order := "DESC"
+	page := " LIMIT $3"
+	reverse := false
+
+	if fq.Position != nil {
+		var op string
+		op, order, reverse = fq.Position.keyset()
+
+		args = append(args, fq.Position.CreatedAt, fq.Position.ID)
+		where = fmt.Sprintf("(e.since, e.id) %s ($%d, $%d)", op, len(args)-1, len(args))
+	} else {
+		args = append(args, fq.Offset)
+		page += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	query := `
+	SELECT e.id, u.username, e.since,
+	  EXISTS (SELECT 1 FROM followers f WHERE f.user_id = $2 AND f.follower_id = e.id) AS is_following,
+	  EXISTS (SELECT 1 FROM followers f WHERE f.user_id = e.id AND f.follower_id = $2) AS follows_you
+	FROM (` + inner + `
+	) e
+	JOIN users u ON u.id = e.id
+	WHERE ` + where + `
+	ORDER BY e.since ` + order + `, e.id ` + order + page
+
 	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
 	defer cancel()
 
+	rows, err := store.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []*FollowListEntry{}
+
+	for rows.Next() {
+		var entry FollowListEntry
+
+		if err := rows.Scan(&entry.ID, &entry.Username, &entry.Since, &entry.IsFollowing, &entry.FollowsYou); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if reverse {
+		slices.Reverse(entries)
+	}
+
+	return entries, nil
+}
+
+func (store *FollowerStore) Stats(ctx context.Context, userID, viewerID int64) (*FollowStats, error) {
 	query := `
-	  DELETE FROM followers WHERE user_id = $1 AND follower_id = $2
+	  SELECT
+	    (SELECT COUNT(*) FROM followers WHERE follower_id = $1),
+	    (SELECT COUNT(*) FROM followers WHERE user_id = $1),
+	    EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1),
+	    EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
 	`
 
-	_, err := store.db.ExecContext(ctx, query, userID, unfollowedID)
-	return err
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	var stats FollowStats
+
+	err := store.db.QueryRowContext(ctx, query, userID, viewerID).Scan(
+		&stats.FollowersCount,
+		&stats.FollowingCount,
+		&stats.IsFollowing,
+		&stats.FollowsYou,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
 }
@@ -0,0 +1,95 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+type BlockStore struct {
+	db *db
+}
+
+// block mirrors a user_blocks or user_mutes row: userID blocked or muted
+// targetID.
+type block struct {
+	userID   int64
+	targetID int64
+}
+
+func (s *BlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	if err := s.db.checkFollowRefs(blockerID, blockedID); err != nil {
+		return store.ErrNotFound
+	}
+
+	key := block{userID: blockerID, targetID: blockedID}
+
+	if _, ok := s.db.blocks[key]; !ok {
+		s.db.blocks[key] = s.db.timestamp()
+	}
+
+	delete(s.db.followers, follow{userID: blockerID, followerID: blockedID})
+	delete(s.db.followers, follow{userID: blockedID, followerID: blockerID})
+	delete(s.db.requests, followRequest{requesterID: blockerID, targetID: blockedID})
+	delete(s.db.requests, followRequest{requesterID: blockedID, targetID: blockerID})
+
+	return nil
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	delete(s.db.blocks, block{userID: blockerID, targetID: blockedID})
+
+	return nil
+}
+
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	return s.db.blockedBetween(userID, otherID), nil
+}
+
+func (s *BlockStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	if err := s.db.checkFollowRefs(muterID, mutedID); err != nil {
+		return store.ErrNotFound
+	}
+
+	key := block{userID: muterID, targetID: mutedID}
+
+	if _, ok := s.db.mutes[key]; !ok {
+		s.db.mutes[key] = s.db.timestamp()
+	}
+
+	return nil
+}
+
+func (s *BlockStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	delete(s.db.mutes, block{userID: muterID, targetID: mutedID})
+
+	return nil
+}
+
+// blockedBetween reports whether either user blocked the other.
+func (d *db) blockedBetween(a, b int64) bool {
+	_, ab := d.blocks[block{userID: a, targetID: b}]
+	_, ba := d.blocks[block{userID: b, targetID: a}]
+
+	return ab || ba
+}
+
+func (d *db) muted(muterID, mutedID int64) bool {
+	_, ok := d.mutes[block{userID: muterID, targetID: mutedID}]
+	return ok
+}
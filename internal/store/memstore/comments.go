@@ -0,0 +1,283 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+type CommentStore struct {
+	db *db
+}
+
+func (s *CommentStore) Create(ctx context.Context, comment *store.Comment) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	if err := s.db.checkCommentRefs(comment); err != nil {
+		return err
+	}
+
+	s.db.insertComment(comment, s.db.timestamp())
+
+	return nil
+}
+
+func (s *CommentStore) CreateBatch(ctx context.Context, comments []*store.Comment) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	for _, comment := range comments {
+		if _, ok := s.db.users[comment.UserID]; !ok {
+			return errForeignKey
+		}
+
+		if _, ok := s.db.posts[comment.PostID]; !ok {
+			return errForeignKey
+		}
+	}
+
+	now := s.db.timestamp()
+
+	for _, comment := range comments {
+		if comment.ParentID != nil {
+			if _, ok := s.db.comments[*comment.ParentID]; !ok {
+				return errForeignKey
+			}
+		}
+
+		s.db.insertComment(comment, parseTimeOr(comment.CreatedAt, now))
+	}
+
+	return nil
+}
+
+// checkCommentRefs enforces the comments foreign keys.
+func (d *db) checkCommentRefs(comment *store.Comment) error {
+	if _, ok := d.users[comment.UserID]; !ok {
+		return errForeignKey
+	}
+
+	if _, ok := d.posts[comment.PostID]; !ok {
+		return errForeignKey
+	}
+
+	if comment.ParentID != nil {
+		if _, ok := d.comments[*comment.ParentID]; !ok {
+			return errForeignKey
+		}
+	}
+
+	return nil
+}
+
+// insertComment stores a new comment. The caller holds the write lock.
+func (d *db) insertComment(comment *store.Comment, createdAt time.Time) {
+	d.lastCommentID++
+
+	comment.ID = d.lastCommentID
+	comment.CreatedAt = formatTime(createdAt)
+	comment.UpdatedAt = comment.CreatedAt
+
+	row := &commentRow{comment: *comment, createdAt: createdAt}
+	row.comment.Replies = nil
+	row.comment.Depth = 0
+	row.comment.User = store.User{}
+
+	if comment.ParentID != nil {
+		parentID := *comment.ParentID
+		row.comment.ParentID = &parentID
+	}
+
+	d.comments[comment.ID] = row
+}
+
+func (s *CommentStore) GetByID(ctx context.Context, commentID int64) (*store.Comment, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	row, ok := s.db.comments[commentID]
+
+	if !ok || row.deleted() {
+		return nil, store.ErrNotFound
+	}
+
+	c := s.db.commentView(row)
+	return &c, nil
+}
+
+// GetByPostID returns the comments of a post in the same thread order as the
+// SQL store: newest threads first, replies oldest first.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) (*[]store.Comment, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	children := map[int64][]*commentRow{}
+	var roots []*commentRow
+
+	for _, row := range s.db.comments {
+		if row.comment.PostID != postID || row.deleted() {
+			continue
+		}
+
+		if row.comment.ParentID == nil {
+			roots = append(roots, row)
+		} else {
+			children[*row.comment.ParentID] = append(children[*row.comment.ParentID], row)
+		}
+	}
+
+	byID := func(a, b *commentRow) int { return cmp.Compare(a.comment.ID, b.comment.ID) }
+
+	slices.SortFunc(roots, func(a, b *commentRow) int { return -byID(a, b) })
+
+	comments := []store.Comment{}
+
+	var walk func(row *commentRow, depth int)
+	walk = func(row *commentRow, depth int) {
+		if !s.db.blockedBetween(viewerID, row.comment.UserID) {
+			c := s.db.commentView(row)
+			c.Depth = depth
+			comments = append(comments, c)
+		}
+
+		replies := children[row.comment.ID]
+		slices.SortFunc(replies, byID)
+
+		for _, reply := range replies {
+			walk(reply, depth+1)
+		}
+	}
+
+	for _, root := range roots {
+		walk(root, 0)
+	}
+
+	return &comments, nil
+}
+
+func (s *CommentStore) UpdateByID(ctx context.Context, comment *store.Comment) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	row, ok := s.db.comments[comment.ID]
+
+	if !ok || row.deleted() {
+		return store.ErrNotFound
+	}
+
+	row.comment.Content = comment.Content
+	row.comment.UpdatedAt = formatTime(s.db.timestamp())
+
+	comment.UpdatedAt = row.comment.UpdatedAt
+
+	return nil
+}
+
+// DeleteByID moves a comment and the replies still under it to the trash,
+// all with the same deletedAt like the SQL store.
+func (s *CommentStore) DeleteByID(ctx context.Context, commentID, deletedBy int64) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	row, ok := s.db.comments[commentID]
+
+	if !ok || row.deleted() {
+		return store.ErrNotFound
+	}
+
+	t := trashed{deletedAt: s.db.now().UTC(), deletedBy: deletedBy}
+
+	s.db.walkReplies(row, func(reply *commentRow) bool {
+		if reply.deleted() {
+			return false
+		}
+
+		reply.trashed = t
+		return true
+	})
+
+	return nil
+}
+
+func (s *CommentStore) Restore(ctx context.Context, postID, commentID, userID int64, retention time.Duration) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	row, ok := s.db.comments[commentID]
+
+	if !ok || row.comment.PostID != postID || (row.comment.UserID != userID && row.deletedBy != userID) ||
+		!s.db.inRetention(row.trashed, retention) || s.db.deletedWithParent(row) {
+		return store.ErrNotFound
+	}
+
+	deletedAt := row.deletedAt
+
+	s.db.walkReplies(row, func(reply *commentRow) bool {
+		if !reply.deletedAt.Equal(deletedAt) {
+			return false
+		}
+
+		reply.trashed = trashed{}
+		return true
+	})
+
+	return nil
+}
+
+// walkReplies calls fn on row and then on its replies, depth first, not
+// descending into the replies of a comment fn returns false for.
+func (d *db) walkReplies(row *commentRow, fn func(*commentRow) bool) {
+	if !fn(row) {
+		return
+	}
+
+	for _, reply := range d.comments {
+		if reply.comment.ParentID != nil && *reply.comment.ParentID == row.comment.ID {
+			d.walkReplies(reply, fn)
+		}
+	}
+}
+
+// deletedWithParent reports whether a trashed comment went to the trash
+// together with its parent.
+func (d *db) deletedWithParent(row *commentRow) bool {
+	if row.comment.ParentID == nil {
+		return false
+	}
+
+	parent, ok := d.comments[*row.comment.ParentID]
+	return ok && parent.deleted() && parent.deletedAt.Equal(row.deletedAt)
+}
+
+// purgeComment removes a comment for good, together with all of its
+// replies, like the ON DELETE CASCADE on parent_id.
+func (d *db) purgeComment(commentID int64) {
+	delete(d.comments, commentID)
+	d.deleteReactions(store.CommentReaction, commentID)
+
+	for id, row := range d.comments {
+		if row.comment.ParentID != nil && *row.comment.ParentID == commentID {
+			d.purgeComment(id)
+		}
+	}
+}
+
+// commentView copies a stored comment and joins in its author.
+func (d *db) commentView(row *commentRow) store.Comment {
+	c := row.comment
+
+	if c.ParentID != nil {
+		parentID := *c.ParentID
+		c.ParentID = &parentID
+	}
+
+	if author, ok := d.users[c.UserID]; ok {
+		c.User = store.User{ID: author.user.ID, Username: author.user.Username}
+	}
+
+	return c
+}
@@ -0,0 +1,292 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+type FollowerStore struct {
+	db *db
+}
+
+// Follow keeps the argument order of the SQL store: userID starts following
+// followedID, or asks to when followedID is a private account.
+func (s *FollowerStore) Follow(ctx context.Context, followedID, userID int64) (bool, error) {
+	s.db.lock()
+	defer s.db.unlock()
+
+	target, ok := s.db.users[followedID]
+
+	if !ok {
+		return false, store.ErrNotFound
+	}
+
+	if err := s.db.checkFollowRefs(userID, followedID); err != nil {
+		return false, err
+	}
+
+	if target.user.IsPrivate {
+		return true, s.db.requestFollow(followedID, userID)
+	}
+
+	key := follow{userID: userID, followerID: followedID}
+
+	if _, ok := s.db.followers[key]; ok {
+		return false, store.ErrConflict
+	}
+
+	if s.db.blockedBetween(userID, followedID) {
+		return false, store.ErrBlocked
+	}
+
+	s.db.followers[key] = s.db.timestamp()
+
+	return false, nil
+}
+
+func (s *FollowerStore) Unfollow(ctx context.Context, unfollowedID, userID int64) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	delete(s.db.followers, follow{userID: userID, followerID: unfollowedID})
+	delete(s.db.requests, followRequest{requesterID: userID, targetID: unfollowedID})
+
+	return nil
+}
+
+func (s *FollowerStore) IsFollowing(ctx context.Context, userID, targetID int64) (bool, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	_, ok := s.db.followers[follow{userID: userID, followerID: targetID}]
+	return ok, nil
+}
+
+// requestFollow leaves userID's follow request with targetID. The caller
+// holds the write lock.
+func (d *db) requestFollow(targetID, userID int64) error {
+	if _, ok := d.followers[follow{userID: userID, followerID: targetID}]; ok {
+		return store.ErrConflict
+	}
+
+	key := followRequest{requesterID: userID, targetID: targetID}
+
+	if _, ok := d.requests[key]; ok {
+		return store.ErrConflict
+	}
+
+	if d.blockedBetween(userID, targetID) {
+		return store.ErrBlocked
+	}
+
+	d.requests[key] = d.timestamp()
+
+	return nil
+}
+
+func (s *FollowerStore) Approve(ctx context.Context, targetID, requesterID int64) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	key := followRequest{requesterID: requesterID, targetID: targetID}
+
+	if _, ok := s.db.requests[key]; !ok {
+		return store.ErrNotFound
+	}
+
+	delete(s.db.requests, key)
+
+	edge := follow{userID: requesterID, followerID: targetID}
+
+	if _, ok := s.db.followers[edge]; !ok {
+		s.db.followers[edge] = s.db.timestamp()
+	}
+
+	return nil
+}
+
+func (s *FollowerStore) Reject(ctx context.Context, targetID, requesterID int64) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	key := followRequest{requesterID: requesterID, targetID: targetID}
+
+	if _, ok := s.db.requests[key]; !ok {
+		return store.ErrNotFound
+	}
+
+	delete(s.db.requests, key)
+
+	return nil
+}
+
+func (s *FollowerStore) FollowBatch(ctx context.Context, follows []store.Follower) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	for _, f := range follows {
+		if err := s.db.checkFollowRefs(f.UserID, f.FollowerID); err != nil {
+			return err
+		}
+	}
+
+	now := s.db.timestamp()
+
+	for _, f := range follows {
+		key := follow{userID: f.UserID, followerID: f.FollowerID}
+
+		if _, ok := s.db.followers[key]; !ok {
+			s.db.followers[key] = now
+		}
+	}
+
+	return nil
+}
+
+func (s *FollowerStore) List(ctx context.Context, list string, userID, viewerID int64, fq store.FollowListQuery) ([]*store.FollowListEntry, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	type edge struct {
+		id    int64
+		since time.Time
+	}
+
+	var edges []edge
+
+	switch list {
+	case store.FollowersList:
+		for f, createdAt := range s.db.followers {
+			if f.followerID == userID {
+				edges = append(edges, edge{f.userID, createdAt})
+			}
+		}
+	case store.FollowingList:
+		for f, createdAt := range s.db.followers {
+			if f.userID == userID {
+				edges = append(edges, edge{f.followerID, createdAt})
+			}
+		}
+	case store.MutualsList:
+		for f, createdAt := range s.db.followers {
+			if f.userID != userID {
+				continue
+			}
+
+			back, ok := s.db.followers[follow{userID: f.followerID, followerID: userID}]
+
+			if !ok {
+				continue
+			}
+
+			if back.After(createdAt) {
+				createdAt = back
+			}
+
+			edges = append(edges, edge{f.followerID, createdAt})
+		}
+	case store.FollowRequestsList:
+		for r, createdAt := range s.db.requests {
+			if r.targetID == userID {
+				edges = append(edges, edge{r.requesterID, createdAt})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown follow list %q", list)
+	}
+
+	byTime := func(a, b edge) int {
+		return cmp.Or(a.since.Compare(b.since), cmp.Compare(a.id, b.id))
+	}
+
+	desc := true
+
+	if fq.Position != nil {
+		pos := edge{id: fq.Position.ID, since: fq.Position.CreatedAt}
+		backward := fq.Position.Direction == store.CursorPrev
+
+		edges = slices.DeleteFunc(edges, func(e edge) bool {
+			if backward {
+				return byTime(e, pos) <= 0
+			}
+
+			return byTime(e, pos) >= 0
+		})
+
+		desc = !backward
+	}
+
+	slices.SortFunc(edges, func(a, b edge) int {
+		if desc {
+			return -byTime(a, b)
+		}
+
+		return byTime(a, b)
+	})
+
+	if fq.Position == nil {
+		edges = edges[min(fq.Offset, len(edges)):]
+	}
+
+	edges = edges[:min(fq.Limit, len(edges))]
+
+	if !desc {
+		slices.Reverse(edges)
+	}
+
+	entries := []*store.FollowListEntry{}
+
+	for _, e := range edges {
+		_, isFollowing := s.db.followers[follow{userID: viewerID, followerID: e.id}]
+		_, followsYou := s.db.followers[follow{userID: e.id, followerID: viewerID}]
+
+		entries = append(entries, &store.FollowListEntry{
+			ID:          e.id,
+			Username:    s.db.username(e.id),
+			Since:       formatTime(e.since),
+			IsFollowing: isFollowing,
+			FollowsYou:  followsYou,
+		})
+	}
+
+	return entries, nil
+}
+
+func (s *FollowerStore) Stats(ctx context.Context, userID, viewerID int64) (*store.FollowStats, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	var stats store.FollowStats
+
+	for f := range s.db.followers {
+		if f.followerID == userID {
+			stats.FollowersCount++
+		}
+
+		if f.userID == userID {
+			stats.FollowingCount++
+		}
+	}
+
+	_, stats.IsFollowing = s.db.followers[follow{userID: viewerID, followerID: userID}]
+	_, stats.FollowsYou = s.db.followers[follow{userID: userID, followerID: viewerID}]
+
+	return &stats, nil
+}
+
+func (d *db) checkFollowRefs(userID, followerID int64) error {
+	if _, ok := d.users[userID]; !ok {
+		return errForeignKey
+	}
+
+	if _, ok := d.users[followerID]; !ok {
+		return errForeignKey
+	}
+
+	return nil
+}
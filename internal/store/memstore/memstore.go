@@ -0,0 +1,286 @@
+// Package memstore is an in-memory implementation of store.Storage. It
+// mirrors the semantics of the Postgres stores closely enough to exercise the
+// HTTP handlers without a database, and is safe for concurrent use.
+package memstore
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+// db is a handle on the tables, either on its own or inside a transaction;
+// see TxStore.
+type db struct {
+	*tables
+	inTx bool
+}
+
+// tables holds every table behind a single lock, so operations that touch
+// several stores see a consistent state.
+type tables struct {
+	mu sync.RWMutex
+
+	now func() time.Time
+
+	users       map[int64]*userRow
+	invitations map[string]invitation
+	roles       []store.Role
+	posts       map[int64]*postRow
+	comments    map[int64]*commentRow
+	followers   map[follow]time.Time
+	reactions   map[reaction]time.Time
+	blocks      map[block]time.Time
+	mutes       map[block]time.Time
+	requests    map[followRequest]time.Time
+
+	lastUserID    int64
+	lastPostID    int64
+	lastCommentID int64
+}
+
+// lock and its siblings take the table lock unless d is a transaction,
+// which holds it already.
+func (d *db) lock() {
+	if !d.inTx {
+		d.mu.Lock()
+	}
+}
+
+func (d *db) unlock() {
+	if !d.inTx {
+		d.mu.Unlock()
+	}
+}
+
+func (d *db) rlock() {
+	if !d.inTx {
+		d.mu.RLock()
+	}
+}
+
+func (d *db) runlock() {
+	if !d.inTx {
+		d.mu.RUnlock()
+	}
+}
+
+type userRow struct {
+	user      store.User
+	createdAt time.Time
+}
+
+type invitation struct {
+	userID int64
+	expiry time.Time
+}
+
+type postRow struct {
+	post      store.Post
+	createdAt time.Time
+	trashed
+}
+
+type commentRow struct {
+	comment   store.Comment
+	createdAt time.Time
+	trashed
+}
+
+// trashed mirrors the deleted_at and deleted_by columns. deletedAt is zero
+// for rows that are not in the trash.
+type trashed struct {
+	deletedAt time.Time
+	deletedBy int64
+}
+
+func (t trashed) deleted() bool {
+	return !t.deletedAt.IsZero()
+}
+
+// inRetention reports whether the row was deleted less than retention ago.
+func (d *db) inRetention(t trashed, retention time.Duration) bool {
+	return t.deleted() && t.deletedAt.After(d.now().Add(-retention))
+}
+
+// follow mirrors a followers row: UserID follows FollowerID.
+type follow struct {
+	userID     int64
+	followerID int64
+}
+
+// followRequest mirrors a follow_requests row.
+type followRequest struct {
+	requesterID int64
+	targetID    int64
+}
+
+func New() store.Storage {
+	d := &db{tables: &tables{
+		now:         time.Now,
+		users:       map[int64]*userRow{},
+		invitations: map[string]invitation{},
+		roles: []store.Role{
+			{ID: 1, Name: "user", Level: 1, Description: "A user can create posts and comments"},
+			{ID: 2, Name: "moderator", Level: 2, Description: "A moderator can update other users posts"},
+			{ID: 3, Name: "admin", Level: 3, Description: "An admin can update and delete other users posts"},
+		},
+		posts:     map[int64]*postRow{},
+		comments:  map[int64]*commentRow{},
+		followers: map[follow]time.Time{},
+		reactions: map[reaction]time.Time{},
+		blocks:    map[block]time.Time{},
+		mutes:     map[block]time.Time{},
+		requests:  map[followRequest]time.Time{},
+	}}
+
+	return newStorage(d)
+}
+
+func newStorage(d *db) store.Storage {
+	return store.Storage{
+		Posts:     &PostStore{d},
+		Users:     &UserStore{d},
+		Comments:  &CommentStore{d},
+		Followers: &FollowerStore{d},
+		Roles:     &RoleStore{d},
+		Search:    &SearchStore{d},
+		Reactions: &ReactionStore{d},
+		Blocks:    &BlockStore{d},
+		Trash:     &TrashStore{d},
+		Tx:        &TxStore{d},
+	}
+}
+
+// TxStore emulates transactions by holding the table lock for the whole of
+// fn and restoring a snapshot of every table when fn fails. Transactions are
+// therefore serializable: nobody else reads or writes while one is running,
+// so a rollback cannot discard their writes. Like withTx, WithTx on a
+// storage handed out by WithTx joins the running transaction.
+type TxStore struct {
+	db *db
+}
+
+func (s *TxStore) WithTx(ctx context.Context, fn func(store.Storage) error) error {
+	if s.db.inTx {
+		return fn(newStorage(s.db))
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	snap := s.db.snapshot()
+
+	defer func() {
+		if p := recover(); p != nil {
+			s.db.restore(snap)
+			panic(p)
+		}
+	}()
+
+	if err := fn(newStorage(&db{tables: s.db.tables, inTx: true})); err != nil {
+		s.db.restore(snap)
+		return err
+	}
+
+	return nil
+}
+
+type snapshot struct {
+	users       map[int64]userRow
+	invitations map[string]invitation
+	posts       map[int64]postRow
+	comments    map[int64]commentRow
+	followers   map[follow]time.Time
+	reactions   map[reaction]time.Time
+	blocks      map[block]time.Time
+	mutes       map[block]time.Time
+	requests    map[followRequest]time.Time
+
+	lastUserID    int64
+	lastPostID    int64
+	lastCommentID int64
+}
+
+// snapshot and restore expect the caller to hold the table lock.
+func (d *db) snapshot() snapshot {
+	return snapshot{
+		users:         copyRows(d.users),
+		invitations:   maps.Clone(d.invitations),
+		posts:         copyRows(d.posts),
+		comments:      copyRows(d.comments),
+		followers:     maps.Clone(d.followers),
+		reactions:     maps.Clone(d.reactions),
+		blocks:        maps.Clone(d.blocks),
+		mutes:         maps.Clone(d.mutes),
+		requests:      maps.Clone(d.requests),
+		lastUserID:    d.lastUserID,
+		lastPostID:    d.lastPostID,
+		lastCommentID: d.lastCommentID,
+	}
+}
+
+func (d *db) restore(snap snapshot) {
+	d.users = restoreRows(snap.users)
+	d.invitations = snap.invitations
+	d.posts = restoreRows(snap.posts)
+	d.comments = restoreRows(snap.comments)
+	d.followers = snap.followers
+	d.reactions = snap.reactions
+	d.blocks = snap.blocks
+	d.mutes = snap.mutes
+	d.requests = snap.requests
+	d.lastUserID = snap.lastUserID
+	d.lastPostID = snap.lastPostID
+	d.lastCommentID = snap.lastCommentID
+}
+
+// copyRows copies the row values, since stores update rows in place.
+func copyRows[K comparable, V any](rows map[K]*V) map[K]V {
+	out := make(map[K]V, len(rows))
+
+	for k, v := range rows {
+		out[k] = *v
+	}
+
+	return out
+}
+
+func restoreRows[K comparable, V any](rows map[K]V) map[K]*V {
+	out := make(map[K]*V, len(rows))
+
+	for k, v := range rows {
+		out[k] = &v
+	}
+
+	return out
+}
+
+// timestamp returns the current time at the second precision of the
+// timestamp(0) columns.
+func (d *db) timestamp() time.Time {
+	return d.now().UTC().Truncate(time.Second)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseTimeOr parses a model timestamp, falling back to def when it is empty
+// or malformed, like the batch inserts do.
+func parseTimeOr(s string, def time.Time) time.Time {
+	if s == "" {
+		return def
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+
+	if err != nil {
+		return def
+	}
+
+	return t.UTC().Truncate(time.Second)
+}
@@ -0,0 +1,155 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+var errAbort = errors.New("abort")
+
+// createUser stores a user in s and returns its id.
+func createUser(t *testing.T, s store.Storage, username string) int64 {
+	t.Helper()
+
+	user := &store.User{Username: username, Email: username + "@example.com"}
+
+	if err := s.Users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user.ID
+}
+
+func assertNoUser(t *testing.T, s store.Storage, userID int64) {
+	t.Helper()
+
+	if _, err := s.Users.GetByID(context.Background(), userID); err != store.ErrNotFound {
+		t.Fatalf("user %d: got error %v, want %v", userID, err, store.ErrNotFound)
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	var userID int64
+
+	err := s.Tx.WithTx(ctx, func(tx store.Storage) error {
+		userID = createUser(t, tx, "alice")
+		return errAbort
+	})
+
+	if err != errAbort {
+		t.Fatalf("got error %v, want %v", err, errAbort)
+	}
+
+	assertNoUser(t, s, userID)
+}
+
+func TestWithTxRollsBackOnPanic(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	var userID int64
+
+	func() {
+		defer func() {
+			if p := recover(); p != errAbort {
+				t.Fatalf("got panic %v, want %v", p, errAbort)
+			}
+		}()
+
+		_ = s.Tx.WithTx(ctx, func(tx store.Storage) error {
+			userID = createUser(t, tx, "alice")
+			panic(errAbort)
+		})
+	}()
+
+	assertNoUser(t, s, userID)
+
+	// The panic must not leave the tables locked.
+	createUser(t, s, "bobby")
+}
+
+func TestWithTxCommits(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	var userID int64
+
+	err := s.Tx.WithTx(ctx, func(tx store.Storage) error {
+		userID = createUser(t, tx, "alice")
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Users.GetByID(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNestedWithTxJoinsTheOuterTransaction(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	var outerID, innerID int64
+
+	err := s.Tx.WithTx(ctx, func(tx store.Storage) error {
+		outerID = createUser(t, tx, "alice")
+
+		err := tx.Tx.WithTx(ctx, func(tx store.Storage) error {
+			innerID = createUser(t, tx, "bobby")
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return errAbort
+	})
+
+	if err != errAbort {
+		t.Fatalf("got error %v, want %v", err, errAbort)
+	}
+
+	// The inner transaction is part of the outer one, so it is rolled back
+	// with it.
+	assertNoUser(t, s, outerID)
+	assertNoUser(t, s, innerID)
+}
+
+func TestRollbackKeepsConcurrentWrites(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+
+	done := make(chan int64)
+
+	err := s.Tx.WithTx(ctx, func(tx store.Storage) error {
+		createUser(t, tx, "alice")
+
+		// This write waits for the transaction to end, so the rollback
+		// must not discard it.
+		go func() {
+			user := &store.User{Username: "bobby", Email: "bobby@example.com"}
+			_ = s.Users.Create(ctx, user)
+			done <- user.ID
+		}()
+
+		return errAbort
+	})
+
+	if err != errAbort {
+		t.Fatalf("got error %v, want %v", err, errAbort)
+	}
+
+	if _, err := s.Users.GetByID(ctx, <-done); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,325 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+type PostStore struct {
+	db *db
+}
+
+func (s *PostStore) Create(ctx context.Context, post *store.Post) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	if _, ok := s.db.users[post.UserID]; !ok {
+		return errForeignKey
+	}
+
+	now := s.db.timestamp()
+
+	post.MentionedUserIDs = s.db.userIDs(store.Mentions(post.Content))
+	s.db.insertPost(post, now)
+
+	return nil
+}
+
+func (s *PostStore) CreateBatch(ctx context.Context, posts []*store.Post) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	for _, post := range posts {
+		if _, ok := s.db.users[post.UserID]; !ok {
+			return errForeignKey
+		}
+	}
+
+	now := s.db.timestamp()
+
+	for _, post := range posts {
+		s.db.insertPost(post, parseTimeOr(post.CreatedAt, now))
+	}
+
+	return nil
+}
+
+// insertPost stores a new post. The caller holds the write lock.
+func (d *db) insertPost(post *store.Post, createdAt time.Time) {
+	d.lastPostID++
+
+	if post.Visibility == "" {
+		post.Visibility = store.VisibilityPublic
+	}
+
+	post.ID = d.lastPostID
+	post.Version = 0
+	post.CreatedAt = formatTime(createdAt)
+	post.UpdatedAt = post.CreatedAt
+
+	row := &postRow{post: *post, createdAt: createdAt}
+	row.post.Tags = slices.Clone(post.Tags)
+	row.post.MentionedUserIDs = slices.Clone(post.MentionedUserIDs)
+	row.post.Comments = nil
+
+	d.posts[post.ID] = row
+}
+
+func (s *PostStore) GetByID(ctx context.Context, postID int64) (*store.Post, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	row, ok := s.db.posts[postID]
+
+	if !ok || row.deleted() {
+		return nil, store.ErrNotFound
+	}
+
+	post := row.post
+	post.Tags = slices.Clone(row.post.Tags)
+	post.MentionedUserIDs = slices.Clone(row.post.MentionedUserIDs)
+
+	return &post, nil
+}
+
+func (s *PostStore) DeleteByID(ctx context.Context, postID, deletedBy int64) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	row, ok := s.db.posts[postID]
+
+	if !ok || row.deleted() {
+		return store.ErrNotFound
+	}
+
+	row.trashed = trashed{deletedAt: s.db.now().UTC(), deletedBy: deletedBy}
+
+	return nil
+}
+
+func (s *PostStore) Restore(ctx context.Context, postID, userID int64, retention time.Duration) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	row, ok := s.db.posts[postID]
+
+	if !ok || (row.post.UserID != userID && row.deletedBy != userID) || !s.db.inRetention(row.trashed, retention) {
+		return store.ErrNotFound
+	}
+
+	row.trashed = trashed{}
+
+	return nil
+}
+
+// purgePost removes a post for good, with what the foreign keys cascade to.
+func (d *db) purgePost(postID int64) {
+	delete(d.posts, postID)
+	d.deleteReactions(store.PostReaction, postID)
+
+	for id, row := range d.comments {
+		if row.comment.PostID == postID {
+			delete(d.comments, id)
+			d.deleteReactions(store.CommentReaction, id)
+		}
+	}
+}
+
+func (s *PostStore) UpdateByID(ctx context.Context, post *store.Post) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	row, ok := s.db.posts[post.ID]
+
+	if !ok || row.deleted() {
+		return store.ErrNotFound
+	}
+
+	if row.post.Version != post.Version {
+		return store.ErrVersionConflict
+	}
+
+	row.post.Title = post.Title
+	row.post.Content = post.Content
+	row.post.Visibility = post.Visibility
+	row.post.MentionedUserIDs = s.db.userIDs(store.Mentions(post.Content))
+	row.post.Version++
+
+	post.Version = row.post.Version
+	post.MentionedUserIDs = slices.Clone(row.post.MentionedUserIDs)
+
+	return nil
+}
+
+func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq store.FeedPaginationQuery) ([]*store.FeedRecord, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	commentCounts := map[int64]int{}
+
+	for _, c := range s.db.comments {
+		if !c.deleted() {
+			commentCounts[c.comment.PostID]++
+		}
+	}
+
+	var rows []*postRow
+
+	for _, row := range s.db.posts {
+		if !s.db.inFeed(userID, row) || !s.db.matchesFeedFilters(row, fq) {
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	sortAsc := fq.Sort == "asc"
+	reverse := false
+
+	if fq.Position != nil {
+		sortAsc = fq.Position.Sort == "asc"
+		backward := fq.Position.Direction == store.CursorPrev
+
+		rows = slices.DeleteFunc(rows, func(row *postRow) bool {
+			c := cmp.Or(row.createdAt.Compare(fq.Position.CreatedAt), cmp.Compare(row.post.ID, fq.Position.ID))
+
+			// Keep rows strictly on the requested side of the cursor.
+			if sortAsc != backward {
+				return c <= 0
+			}
+
+			return c >= 0
+		})
+
+		if backward {
+			sortAsc = !sortAsc
+			reverse = true
+		}
+	}
+
+	slices.SortFunc(rows, func(a, b *postRow) int {
+		c := cmp.Or(a.createdAt.Compare(b.createdAt), cmp.Compare(a.post.ID, b.post.ID))
+
+		if !sortAsc {
+			return -c
+		}
+
+		return c
+	})
+
+	if fq.Position == nil {
+		rows = rows[min(fq.Offset, len(rows)):]
+	}
+
+	rows = rows[:min(fq.Limit, len(rows))]
+
+	var feed []*store.FeedRecord
+
+	for _, row := range rows {
+		record := &store.FeedRecord{Post: row.post, CommentsCount: commentCounts[row.post.ID]}
+		record.Tags = slices.Clone(row.post.Tags)
+		record.MentionedUserIDs = slices.Clone(row.post.MentionedUserIDs)
+		record.Reactions = s.db.reactionSummary(store.PostReaction, row.post.ID, userID)
+
+		if author, ok := s.db.users[row.post.UserID]; ok {
+			record.User.Username = author.user.Username
+		}
+
+		feed = append(feed, record)
+	}
+
+	if reverse {
+		slices.Reverse(feed)
+	}
+
+	return feed, nil
+}
+
+// inFeed reports whether a post shows up in userID's feed: it is not in the
+// trash, and it is their own or written by someone they follow, who is
+// neither blocked nor muted, and they may see it.
+func (d *db) inFeed(userID int64, row *postRow) bool {
+	if row.deleted() {
+		return false
+	}
+
+	if row.post.UserID == userID {
+		return true
+	}
+
+	if d.blockedBetween(userID, row.post.UserID) || d.muted(userID, row.post.UserID) {
+		return false
+	}
+
+	_, follows := d.followers[follow{userID: userID, followerID: row.post.UserID}]
+	return follows && d.postVisibleTo(&row.post, userID)
+}
+
+// postVisibleTo applies Post.VisibleTo with what the database knows about
+// the author.
+func (d *db) postVisibleTo(post *store.Post, viewerID int64) bool {
+	author, ok := d.users[post.UserID]
+	_, follows := d.followers[follow{userID: viewerID, followerID: post.UserID}]
+
+	return post.VisibleTo(viewerID, ok && author.user.IsPrivate, follows)
+}
+
+// userIDs resolves usernames to ids, skipping names nobody has.
+func (d *db) userIDs(usernames []string) []int64 {
+	var ids []int64
+
+	for _, row := range d.users {
+		if slices.Contains(usernames, row.user.Username) {
+			ids = append(ids, row.user.ID)
+		}
+	}
+
+	slices.Sort(ids)
+
+	return ids
+}
+
+func (d *db) matchesFeedFilters(row *postRow, fq store.FeedPaginationQuery) bool {
+	if len(fq.Tags) > 0 && !slices.ContainsFunc(fq.Tags, func(tag string) bool {
+		return slices.Contains(row.post.Tags, tag)
+	}) {
+		return false
+	}
+
+	if fq.Search != "" && !d.postMentions(row, fq.Search) {
+		return false
+	}
+
+	if fq.Since != nil && row.createdAt.Before(*fq.Since) {
+		return false
+	}
+
+	if fq.Until != nil && row.createdAt.After(*fq.Until) {
+		return false
+	}
+
+	return true
+}
+
+func (d *db) postMentions(row *postRow, search string) bool {
+	if containsFold(row.post.Title, search) || containsFold(row.post.Content, search) {
+		return true
+	}
+
+	for _, c := range d.comments {
+		if c.comment.PostID == row.post.ID && !c.deleted() && containsFold(c.comment.Content, search) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,113 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+type ReactionStore struct {
+	db *db
+}
+
+// reaction mirrors a post_reactions or comment_reactions row.
+type reaction struct {
+	target   store.ReactionTarget
+	targetID int64
+	userID   int64
+	kind     string
+}
+
+func (s *ReactionStore) Add(ctx context.Context, target store.ReactionTarget, targetID, userID int64, kind string) error {
+	if !store.ValidReaction(kind) {
+		return store.ErrInvalidReaction
+	}
+
+	s.db.lock()
+	defer s.db.unlock()
+
+	if !s.db.reactionTargetExists(target, targetID) {
+		return store.ErrNotFound
+	}
+
+	if _, ok := s.db.users[userID]; !ok {
+		return store.ErrNotFound
+	}
+
+	key := reaction{target: target, targetID: targetID, userID: userID, kind: kind}
+
+	if _, ok := s.db.reactions[key]; !ok {
+		s.db.reactions[key] = s.db.timestamp()
+	}
+
+	return nil
+}
+
+func (s *ReactionStore) Remove(ctx context.Context, target store.ReactionTarget, targetID, userID int64, kind string) error {
+	if !store.ValidReaction(kind) {
+		return store.ErrInvalidReaction
+	}
+
+	s.db.lock()
+	defer s.db.unlock()
+
+	delete(s.db.reactions, reaction{target: target, targetID: targetID, userID: userID, kind: kind})
+
+	return nil
+}
+
+func (s *ReactionStore) Summaries(ctx context.Context, target store.ReactionTarget, targetIDs []int64, viewerID int64) (map[int64]*store.ReactionSummary, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	summaries := make(map[int64]*store.ReactionSummary, len(targetIDs))
+
+	for _, id := range targetIDs {
+		summaries[id] = s.db.reactionSummary(target, id, viewerID)
+	}
+
+	return summaries, nil
+}
+
+func (d *db) reactionTargetExists(target store.ReactionTarget, targetID int64) bool {
+	switch target {
+	case store.PostReaction:
+		_, ok := d.posts[targetID]
+		return ok
+	case store.CommentReaction:
+		_, ok := d.comments[targetID]
+		return ok
+	default:
+		return false
+	}
+}
+
+// deleteReactions is the ON DELETE CASCADE of the reaction tables.
+func (d *db) deleteReactions(target store.ReactionTarget, targetID int64) {
+	for r := range d.reactions {
+		if r.target == target && r.targetID == targetID {
+			delete(d.reactions, r)
+		}
+	}
+}
+
+func (d *db) reactionSummary(target store.ReactionTarget, targetID, viewerID int64) *store.ReactionSummary {
+	summary := store.NewReactionSummary()
+
+	for r := range d.reactions {
+		if r.target != target || r.targetID != targetID {
+			continue
+		}
+
+		summary.Counts[r.kind]++
+
+		if r.userID == viewerID {
+			summary.Mine = append(summary.Mine, r.kind)
+		}
+	}
+
+	slices.Sort(summary.Mine)
+
+	return summary
+}
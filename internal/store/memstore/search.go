@@ -0,0 +1,161 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+type SearchStore struct {
+	db *db
+}
+
+// Search approximates full-text search with case-insensitive word matching.
+// The rank is the share of query words found in the document, which keeps
+// the ordering and cursor behaviour of the SQL store without Postgres'
+// text search machinery.
+func (s *SearchStore) Search(ctx context.Context, sq store.SearchQuery) ([]*store.SearchResult, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	words := strings.Fields(strings.ToLower(sq.Query))
+
+	var results []*store.SearchResult
+
+	add := func(result store.SearchResult, text string) {
+		result.Type = sq.Type
+		result.Rank = rank(words, text)
+
+		if result.Rank > 0 {
+			result.Snippet = store.Snippet(text)
+			results = append(results, &result)
+		}
+	}
+
+	switch sq.Type {
+	case store.SearchPosts:
+		for _, row := range s.db.posts {
+			if row.deleted() || !s.db.searchable(&row.post, sq.ViewerID) {
+				continue
+			}
+
+			add(store.SearchResult{
+				ID:        row.post.ID,
+				PostID:    row.post.ID,
+				UserID:    row.post.UserID,
+				Username:  s.db.username(row.post.UserID),
+				Title:     row.post.Title,
+				CreatedAt: row.post.CreatedAt,
+			}, row.post.Title+" "+row.post.Content)
+		}
+	case store.SearchComments:
+		for _, row := range s.db.comments {
+			if row.deleted() || s.db.blockedBetween(sq.ViewerID, row.comment.UserID) {
+				continue
+			}
+
+			if post, ok := s.db.posts[row.comment.PostID]; !ok || post.deleted() || !s.db.searchable(&post.post, sq.ViewerID) {
+				continue
+			}
+
+			add(store.SearchResult{
+				ID:        row.comment.ID,
+				PostID:    row.comment.PostID,
+				UserID:    row.comment.UserID,
+				Username:  s.db.username(row.comment.UserID),
+				CreatedAt: row.comment.CreatedAt,
+			}, row.comment.Content)
+		}
+	case store.SearchUsers:
+		for _, row := range s.db.users {
+			add(store.SearchResult{
+				ID:        row.user.ID,
+				UserID:    row.user.ID,
+				Username:  row.user.Username,
+				CreatedAt: row.user.CreatedAt,
+			}, row.user.Username)
+		}
+	default:
+		return nil, fmt.Errorf("unknown search type %q", sq.Type)
+	}
+
+	byRank := func(a, b *store.SearchResult) int {
+		return cmp.Or(cmp.Compare(a.Rank, b.Rank), cmp.Compare(a.ID, b.ID))
+	}
+
+	desc := true
+
+	if sq.Position != nil {
+		pos := &store.SearchResult{Rank: sq.Position.Rank, ID: sq.Position.ID}
+		backward := sq.Position.Direction == store.CursorPrev
+
+		results = slices.DeleteFunc(results, func(r *store.SearchResult) bool {
+			if backward {
+				return byRank(r, pos) <= 0
+			}
+
+			return byRank(r, pos) >= 0
+		})
+
+		desc = !backward
+	}
+
+	slices.SortFunc(results, func(a, b *store.SearchResult) int {
+		if desc {
+			return -byRank(a, b)
+		}
+
+		return byRank(a, b)
+	})
+
+	if sq.Position == nil {
+		results = results[min(sq.Offset, len(results)):]
+	}
+
+	results = results[:min(sq.Limit, len(results))]
+
+	if !desc {
+		slices.Reverse(results)
+	}
+
+	if results == nil {
+		results = []*store.SearchResult{}
+	}
+
+	return results, nil
+}
+
+// searchable reports whether the results of post, or of its comments, may be
+// shown to viewerID.
+func (d *db) searchable(post *store.Post, viewerID int64) bool {
+	return !d.blockedBetween(viewerID, post.UserID) && d.postVisibleTo(post, viewerID)
+}
+
+func rank(words []string, text string) float32 {
+	if len(words) == 0 {
+		return 0
+	}
+
+	text = strings.ToLower(text)
+	found := 0
+
+	for _, w := range words {
+		if strings.Contains(text, w) {
+			found++
+		}
+	}
+
+	return float32(found) / float32(len(words))
+}
+
+func (d *db) username(userID int64) string {
+	if row, ok := d.users[userID]; ok {
+		return row.user.Username
+	}
+
+	return ""
+}
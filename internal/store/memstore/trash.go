@@ -0,0 +1,109 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+type TrashStore struct {
+	db *db
+}
+
+func (s *TrashStore) List(ctx context.Context, userID int64, retention time.Duration, tq store.TrashQuery) ([]*store.TrashItem, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	type entry struct {
+		item      *store.TrashItem
+		deletedAt time.Time
+	}
+
+	var entries []entry
+
+	add := func(t trashed, item store.TrashItem) {
+		item.DeletedBy = t.deletedBy
+		item.DeletedAt = formatTime(t.deletedAt)
+		item.ExpiresAt = formatTime(t.deletedAt.Add(retention))
+		entries = append(entries, entry{&item, t.deletedAt})
+	}
+
+	for _, row := range s.db.posts {
+		if row.post.UserID != userID || !s.db.inRetention(row.trashed, retention) {
+			continue
+		}
+
+		add(row.trashed, store.TrashItem{
+			Type:    store.TrashPost,
+			ID:      row.post.ID,
+			PostID:  row.post.ID,
+			UserID:  row.post.UserID,
+			Title:   row.post.Title,
+			Content: row.post.Content,
+		})
+	}
+
+	for _, row := range s.db.comments {
+		if row.comment.UserID != userID || !s.db.inRetention(row.trashed, retention) || s.db.deletedWithParent(row) {
+			continue
+		}
+
+		add(row.trashed, store.TrashItem{
+			Type:    store.TrashComment,
+			ID:      row.comment.ID,
+			PostID:  row.comment.PostID,
+			UserID:  row.comment.UserID,
+			Content: row.comment.Content,
+		})
+	}
+
+	// Most recently deleted first, posts before comments, like the SQL
+	// store.
+	slices.SortFunc(entries, func(a, b entry) int {
+		return -cmp.Or(
+			a.deletedAt.Compare(b.deletedAt),
+			cmp.Compare(a.item.Type, b.item.Type),
+			cmp.Compare(a.item.ID, b.item.ID),
+		)
+	})
+
+	entries = entries[min(tq.Offset, len(entries)):]
+	entries = entries[:min(tq.Limit, len(entries))]
+
+	items := []*store.TrashItem{}
+
+	for _, e := range entries {
+		items = append(items, e.item)
+	}
+
+	return items, nil
+}
+
+func (s *TrashStore) Purge(ctx context.Context, retention time.Duration) (posts, comments int64, err error) {
+	s.db.lock()
+	defer s.db.unlock()
+
+	for id, row := range s.db.posts {
+		if row.deleted() && !s.db.inRetention(row.trashed, retention) {
+			s.db.purgePost(id)
+			posts++
+		}
+	}
+
+	for id, row := range s.db.comments {
+		// A reply may already be gone with its purged parent.
+		if _, ok := s.db.comments[id]; !ok {
+			continue
+		}
+
+		if row.deleted() && !s.db.inRetention(row.trashed, retention) {
+			s.db.purgeComment(id)
+			comments++
+		}
+	}
+
+	return posts, comments, nil
+}
@@ -0,0 +1,257 @@
+package memstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+)
+
+type UserStore struct {
+	db *db
+}
+
+func (s *UserStore) Create(ctx context.Context, user *store.User) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	return s.db.insertUser(user, s.db.timestamp())
+}
+
+func (s *UserStore) CreateBatch(ctx context.Context, users []*store.User) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	// Validate the whole batch first, the SQL version is all or nothing.
+	seen := map[string]bool{}
+
+	for _, user := range users {
+		if err := s.db.checkUnique(user); err != nil {
+			return err
+		}
+
+		email, username := "e:"+strings.ToLower(user.Email), "u:"+user.Username
+
+		if seen[email] {
+			return store.ErrDuplicateEmail
+		}
+
+		if seen[username] {
+			return store.ErrDuplicateUsername
+		}
+
+		seen[email], seen[username] = true, true
+	}
+
+	now := s.db.timestamp()
+
+	for _, user := range users {
+		isActive := user.IsActive
+
+		if err := s.db.insertUser(user, parseTimeOr(user.CreatedAt, now)); err != nil {
+			return err
+		}
+
+		s.db.users[user.ID].user.IsActive = isActive
+		user.IsActive = isActive
+	}
+
+	return nil
+}
+
+// insertUser stores a new, inactive user. The caller holds the write lock.
+func (d *db) insertUser(user *store.User, createdAt time.Time) error {
+	if err := d.checkUnique(user); err != nil {
+		return err
+	}
+
+	roleName := user.Role.Name
+
+	if roleName == "" {
+		roleName = "user"
+	}
+
+	role, ok := d.role(roleName)
+
+	if !ok {
+		return fmt.Errorf("unknown role %q", roleName)
+	}
+
+	d.lastUserID++
+
+	user.ID = d.lastUserID
+	user.IsActive = false
+	user.RoleID = role.ID
+	user.CreatedAt = formatTime(createdAt)
+
+	row := &userRow{user: *user, createdAt: createdAt}
+	row.user.Role = role
+
+	d.users[user.ID] = row
+
+	return nil
+}
+
+func (s *UserStore) SetPrivate(ctx context.Context, userID int64, private bool) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	row, ok := s.db.users[userID]
+
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	row.user.IsPrivate = private
+
+	if private {
+		return nil
+	}
+
+	now := s.db.timestamp()
+
+	for r := range s.db.requests {
+		if r.targetID != userID {
+			continue
+		}
+
+		edge := follow{userID: r.requesterID, followerID: userID}
+
+		if _, ok := s.db.followers[edge]; !ok {
+			s.db.followers[edge] = now
+		}
+
+		delete(s.db.requests, r)
+	}
+
+	return nil
+}
+
+func (d *db) checkUnique(user *store.User) error {
+	for _, row := range d.users {
+		// email is citext in Postgres.
+		if strings.EqualFold(row.user.Email, user.Email) {
+			return store.ErrDuplicateEmail
+		}
+
+		if row.user.Username == user.Username {
+			return store.ErrDuplicateUsername
+		}
+	}
+
+	return nil
+}
+
+func (s *UserStore) GetByID(ctx context.Context, userID int64) (*store.User, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	row, ok := s.db.users[userID]
+
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	user := row.user
+	return &user, nil
+}
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	for _, row := range s.db.users {
+		if strings.EqualFold(row.user.Email, email) && row.user.IsActive {
+			user := row.user
+			return &user, nil
+		}
+	}
+
+	return nil, store.ErrNotFound
+}
+
+func (s *UserStore) CreateAndInvite(ctx context.Context, user *store.User, token string, invitationExp time.Duration) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	now := s.db.timestamp()
+
+	if err := s.db.insertUser(user, now); err != nil {
+		return err
+	}
+
+	s.db.invitations[hashToken(token)] = invitation{
+		userID: user.ID,
+		expiry: s.db.now().Add(invitationExp),
+	}
+
+	return nil
+}
+
+func (s *UserStore) Activate(ctx context.Context, token string) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	inv, ok := s.db.invitations[hashToken(token)]
+
+	if !ok || !inv.expiry.After(s.db.now()) {
+		return store.ErrNotFound
+	}
+
+	row, ok := s.db.users[inv.userID]
+
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	row.user.IsActive = true
+
+	for key, other := range s.db.invitations {
+		if other.userID == inv.userID {
+			delete(s.db.invitations, key)
+		}
+	}
+
+	return nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+type RoleStore struct {
+	db *db
+}
+
+func (s *RoleStore) GetByName(ctx context.Context, name string) (*store.Role, error) {
+	s.db.rlock()
+	defer s.db.runlock()
+
+	role, ok := s.db.role(name)
+
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &role, nil
+}
+
+func (d *db) role(name string) (store.Role, bool) {
+	for _, role := range d.roles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+
+	return store.Role{}, false
+}
+
+// errForeignKey stands in for the foreign key violations Postgres reports
+// when a row points at a missing user or post.
+var errForeignKey = errors.New("memstore: foreign key violation")
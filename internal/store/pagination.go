@@ -1,14 +1,38 @@
 package store
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// ParamError reports a query parameter that could not be parsed. Expected
+// describes what the parameter should have been.
+type ParamError struct {
+	Param    string
+	Value    string
+	Expected string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid %s %q: expected %s", e.Param, e.Value, e.Expected)
+}
+
 type FeedPaginationQuery struct {
-	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
-	Offset int    `json:"offset" validate:"gte=0"`
-	Sort   string `json:"sort" validate:"omitempty,oneof=asc desc"`
+	Limit  int        `json:"limit" validate:"gte=1,lte=20"`
+	Offset int        `json:"offset" validate:"gte=0"`
+	Sort   string     `json:"sort" validate:"omitempty,oneof=asc desc"`
+	Cursor string     `json:"cursor" validate:"omitempty,max=512"`
+	Tags   []string   `json:"tags" validate:"max=5,dive,required,max=100"`
+	Search string     `json:"search" validate:"max=100"`
+	Since  *time.Time `json:"since"`
+	Until  *time.Time `json:"until"`
+
+	// Position is the decoded Cursor. When it is nil the feed falls back to
+	// LIMIT/OFFSET pagination.
+	Position *Cursor `json:"-"`
 }
 
 func (fpq FeedPaginationQuery) Parse(r *http.Request) (FeedPaginationQuery, error) {
@@ -20,7 +44,7 @@ func (fpq FeedPaginationQuery) Parse(r *http.Request) (FeedPaginationQuery, erro
 		l, err := strconv.Atoi(limit)
 
 		if err != nil {
-			return fpq, nil
+			return fpq, &ParamError{Param: "limit", Value: limit, Expected: "an integer"}
 		}
 
 		fpq.Limit = l
@@ -32,7 +56,7 @@ func (fpq FeedPaginationQuery) Parse(r *http.Request) (FeedPaginationQuery, erro
 		o, err := strconv.Atoi(offset)
 
 		if err != nil {
-			return fpq, nil
+			return fpq, &ParamError{Param: "offset", Value: offset, Expected: "an integer"}
 		}
 
 		fpq.Offset = o
@@ -43,5 +67,74 @@ func (fpq FeedPaginationQuery) Parse(r *http.Request) (FeedPaginationQuery, erro
 		fpq.Sort = sort
 	}
 
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		fpq.Cursor = cursor
+	}
+
+	tags := qs.Get("tags")
+	if tags != "" {
+		fpq.Tags = strings.Split(tags, ",")
+
+		for i, tag := range fpq.Tags {
+			fpq.Tags[i] = strings.TrimSpace(tag)
+		}
+	}
+
+	search := strings.TrimSpace(qs.Get("search"))
+	if search != "" {
+		fpq.Search = search
+	}
+
+	since := qs.Get("since")
+	if since != "" {
+		t, _, err := parseTime(since)
+
+		if err != nil {
+			return fpq, &ParamError{Param: "since", Value: since, Expected: "an RFC 3339 time or YYYY-MM-DD date"}
+		}
+
+		fpq.Since = &t
+	}
+
+	until := qs.Get("until")
+	if until != "" {
+		t, dateOnly, err := parseTime(until)
+
+		if err != nil {
+			return fpq, &ParamError{Param: "until", Value: until, Expected: "an RFC 3339 time or YYYY-MM-DD date"}
+		}
+
+		// A bare date includes the whole day.
+		if dateOnly {
+			t = t.Add(24*time.Hour - time.Second)
+		}
+
+		fpq.Until = &t
+	}
+
+	if fpq.Since != nil && fpq.Until != nil && fpq.Until.Before(*fpq.Since) {
+		return fpq, &ParamError{Param: "until", Value: until, Expected: "a time not before since"}
+	}
+
 	return fpq, nil
 }
+
+func parseTime(s string) (t time.Time, dateOnly bool, err error) {
+	// An unencoded "+" in an offset such as +03:30 arrives as a space.
+	s = strings.Replace(s, " ", "+", 1)
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+
+	t, err = time.Parse(time.DateOnly, s)
+	return t, true, err
+}
+
+// likePattern wraps s for a substring ILIKE match, escaping the characters
+// LIKE treats as wildcards.
+func likePattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(s) + "%"
+}
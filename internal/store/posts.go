@@ -3,13 +3,18 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type PostStore struct {
-	db *sql.DB
+	db DBTX
 }
 
 type Post struct {
@@ -23,6 +28,13 @@ type Post struct {
 	Version   int       `json:"version"`
 	CreatedAt string    `json:"created_at"`
 	UpdatedAt string    `json:"updated_at"`
+
+	Visibility       string  `json:"visibility"`
+	MentionedUserIDs []int64 `json:"mentioned_user_ids"`
+
+	// Reactions is only loaded where a caller is known, as it includes the
+	// caller's own reactions.
+	Reactions *ReactionSummary `json:"reactions,omitempty"`
 }
 
 type FeedRecord struct {
@@ -30,14 +42,22 @@ type FeedRecord struct {
 	CommentsCount int `json:"comments_count"`
 }
 
+// mentionedIDs resolves the usernames bound to %s into an array of user ids.
+const mentionedIDs = `ARRAY(SELECT id FROM users WHERE username = ANY(%s))`
+
 func (ps *PostStore) Create(ctx context.Context, post *Post) error {
 	query := `
-	  INSERT INTO posts (title, user_id, content, tags)
-	  VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+	  INSERT INTO posts (title, user_id, content, tags, visibility, mentioned_user_ids)
+	  VALUES ($1, $2, $3, $4, $5, ` + fmt.Sprintf(mentionedIDs, "$6") + `)
+	  RETURNING id, mentioned_user_ids, created_at, updated_at
 	`
 	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
 	defer cancel()
 
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
+
 	err := ps.db.QueryRowContext(
 		ctx,
 		query,
@@ -45,8 +65,11 @@ func (ps *PostStore) Create(ctx context.Context, post *Post) error {
 		post.UserID,
 		post.Content,
 		pq.Array(post.Tags),
+		post.Visibility,
+		pq.Array(Mentions(post.Content)),
 	).Scan(
 		&post.ID,
+		pq.Array(&post.MentionedUserIDs),
 		&post.CreatedAt,
 		&post.UpdatedAt,
 	)
@@ -58,10 +81,39 @@ func (ps *PostStore) Create(ctx context.Context, post *Post) error {
 	return nil
 }
 
+// CreateBatch inserts many posts in one transaction. It is meant for seeding
+// and, unlike Create, honours CreatedAt.
+func (ps *PostStore) CreateBatch(ctx context.Context, posts []*Post) error {
+	return withTx(ctx, ps.db, func(tx *sql.Tx) error {
+		ids, err := reserveIDs(ctx, tx, "posts_id_seq", len(posts))
+
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]any, len(posts))
+
+		for i, post := range posts {
+			if post.Visibility == "" {
+				post.Visibility = VisibilityPublic
+			}
+
+			post.ID = ids[i]
+			createdAt := timeOrNow(post.CreatedAt)
+			rows[i] = []any{post.ID, post.Title, post.UserID, post.Content, pq.Array(post.Tags), post.Visibility, createdAt, createdAt}
+		}
+
+		columns := []string{"id", "title", "user_id", "content", "tags", "visibility", "created_at", "updated_at"}
+
+		return batchInsert(ctx, tx, "posts", columns, rows, "")
+	})
+}
+
 func (ps *PostStore) GetByID(ctx context.Context, postID int64) (*Post, error) {
 	query := `
-		SELECT id, user_id, title, content, version, created_at, updated_at, tags
-		FROM posts WHERE id = $1 LIMIT 1;
+		SELECT id, user_id, title, content, version, created_at, updated_at, tags,
+		  visibility, mentioned_user_ids
+		FROM posts WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
@@ -78,6 +130,8 @@ func (ps *PostStore) GetByID(ctx context.Context, postID int64) (*Post, error) {
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
+		&post.Visibility,
+		pq.Array(&post.MentionedUserIDs),
 	)
 
 	if err != nil {
@@ -92,13 +146,48 @@ func (ps *PostStore) GetByID(ctx context.Context, postID int64) (*Post, error) {
 	return &post, nil
 }
 
-func (ps *PostStore) DeleteByID(ctx context.Context, postID int64) error {
-	query := `DELETE FROM posts WHERE id = $1`
+// DeleteByID moves a post to its author's trash, recording that deletedBy
+// deleted it. Its comments and
+// reactions stay until the post is purged, so restoring it brings them back.
+func (ps *PostStore) DeleteByID(ctx context.Context, postID, deletedBy int64) error {
+	query := `
+	  UPDATE posts SET deleted_at = NOW(), deleted_by = $2
+	  WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	res, err := ps.db.ExecContext(ctx, query, postID, deletedBy)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Restore takes a post deleted less than retention ago out of the trash.
+// userID must be its author or whoever deleted it.
+func (ps *PostStore) Restore(ctx context.Context, postID, userID int64, retention time.Duration) error {
+	query := `
+	  UPDATE posts SET deleted_at = NULL, deleted_by = NULL
+	  WHERE id = $1 AND (user_id = $2 OR deleted_by = $2) AND ` + fmt.Sprintf(inRetention, "deleted_at", "$3")
 
 	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
 	defer cancel()
 
-	res, err := ps.db.ExecContext(ctx, query, postID)
+	res, err := ps.db.ExecContext(ctx, query, postID, userID, retention.Seconds())
 
 	if err != nil {
 		return err
@@ -120,20 +209,31 @@ func (ps *PostStore) DeleteByID(ctx context.Context, postID int64) error {
 func (ps *PostStore) UpdateByID(ctx context.Context, post *Post) error {
 	query := `
 	UPDATE posts
-	SET title = $1, content = $2, version = version + 1
-	WHERE id = $3 AND version = $4
-	RETURNING version
+	SET title = $1, content = $2, visibility = $3,
+	  mentioned_user_ids = ` + fmt.Sprintf(mentionedIDs, "$4") + `,
+	  version = version + 1
+	WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+	RETURNING version, mentioned_user_ids
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
 	defer cancel()
 
-	err := ps.db.QueryRowContext(ctx, query, post.Title, post.Content, post.ID, post.Version).Scan(&post.Version)
+	err := ps.db.QueryRowContext(
+		ctx,
+		query,
+		post.Title,
+		post.Content,
+		post.Visibility,
+		pq.Array(Mentions(post.Content)),
+		post.ID,
+		post.Version,
+	).Scan(&post.Version, pq.Array(&post.MentionedUserIDs))
 
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
+			return ps.missingOrStale(ctx, post.ID)
 		default:
 			return err
 		}
@@ -142,7 +242,84 @@ func (ps *PostStore) UpdateByID(ctx context.Context, post *Post) error {
 	return nil
 }
 
+// missingOrStale tells apart the two reasons a versioned update can match no
+// rows: the post is gone, or somebody else updated it first.
+func (ps *PostStore) missingOrStale(ctx context.Context, postID int64) error {
+	query := `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)`
+
+	var exists bool
+
+	if err := ps.db.QueryRowContext(ctx, query, postID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrVersionConflict
+	}
+
+	return ErrNotFound
+}
+
+// GetUserFeed returns userID's own posts and those of the users they follow
+// that userID may see.
 func (ps *PostStore) GetUserFeed(ctx context.Context, userID int64, fq FeedPaginationQuery) ([]*FeedRecord, error) {
+	args := []any{userID, fq.Limit}
+
+	where := `(
+		p.user_id = $1
+		OR p.user_id IN (
+			SELECT f.follower_id
+			FROM followers f
+			WHERE f.user_id = $1
+		)
+	)
+	AND p.deleted_at IS NULL
+	AND NOT ` + fmt.Sprintf(blockedBetween, "$1", "p.user_id") + `
+	AND NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = $1 AND m.muted_id = p.user_id)
+	AND ` + fmt.Sprintf(postVisibleTo, "p", "$1")
+
+	if len(fq.Tags) > 0 {
+		args = append(args, pq.Array(fq.Tags))
+		where += fmt.Sprintf(" AND p.tags && $%d", len(args))
+	}
+
+	if fq.Search != "" {
+		args = append(args, likePattern(fq.Search))
+		where += fmt.Sprintf(` AND (
+			p.title ILIKE $%[1]d
+			OR p.content ILIKE $%[1]d
+			OR EXISTS (
+				SELECT 1 FROM comments c
+				WHERE c.post_id = p.id AND c.deleted_at IS NULL AND c.content ILIKE $%[1]d
+			)
+		)`, len(args))
+	}
+
+	if fq.Since != nil {
+		args = append(args, *fq.Since)
+		where += fmt.Sprintf(" AND p.created_at >= $%d", len(args))
+	}
+
+	if fq.Until != nil {
+		args = append(args, *fq.Until)
+		where += fmt.Sprintf(" AND p.created_at <= $%d", len(args))
+	}
+
+	order := strings.ToUpper(fq.Sort)
+	page := ` LIMIT $2`
+	reverse := false
+
+	if fq.Position != nil {
+		var op string
+		op, order, reverse = fq.Position.keyset()
+
+		args = append(args, fq.Position.CreatedAt, fq.Position.ID)
+		where += fmt.Sprintf(" AND (p.created_at, p.id) %s ($%d, $%d)", op, len(args)-1, len(args))
+	} else {
+		args = append(args, fq.Offset)
+		page += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
 	query := `
 	SELECT
 	p.id,
@@ -152,29 +329,39 @@ func (ps *PostStore) GetUserFeed(ctx context.Context, userID int64, fq FeedPagin
 	p.created_at,
 	p.version,
 	p.tags,
+	p.visibility,
+	p.mentioned_user_ids,
 	u.username,
-	COALESCE(comment_counts.comment_count, 0) AS comment_count
+	COALESCE(comment_counts.comment_count, 0) AS comment_count,
+	reactions.counts,
+	reactions.mine
 	FROM
 	posts p
 	LEFT JOIN (
 		SELECT post_id, COUNT(*) AS comment_count
 		FROM comments
+		WHERE deleted_at IS NULL
 		GROUP BY post_id
 	) comment_counts ON p.id = comment_counts.post_id
+	LEFT JOIN LATERAL (
+		SELECT
+		jsonb_object_agg(r.type, r.count) AS counts,
+		array_agg(r.type ORDER BY r.type) FILTER (WHERE r.mine) AS mine
+		FROM (
+			SELECT type, COUNT(*) AS count, BOOL_OR(user_id = $1) AS mine
+			FROM post_reactions
+			WHERE post_id = p.id
+			GROUP BY type
+		) r
+	) reactions ON true
 	LEFT JOIN users u ON p.user_id = u.id
-	WHERE
-	p.user_id = $1
-	OR p.user_id IN (
-		SELECT f.follower_id
-		FROM followers f
-		WHERE f.user_id = $1
-	)
-	ORDER BY p.created_at ` + fq.Sort + ` LIMIT $2 OFFSET $3
-	`
+	WHERE ` + where + `
+	ORDER BY p.created_at ` + order + `, p.id ` + order + page
+
 	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
 	defer cancel()
 
-	rows, err := ps.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset)
+	rows, err := ps.db.QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return nil, err
@@ -185,7 +372,12 @@ func (ps *PostStore) GetUserFeed(ctx context.Context, userID int64, fq FeedPagin
 	var feedRecords []*FeedRecord
 
 	for rows.Next() {
-		var record FeedRecord
+		var (
+			record         FeedRecord
+			reactionCounts []byte
+		)
+
+		record.Reactions = NewReactionSummary()
 
 		err := rows.Scan(
 			&record.ID,
@@ -195,16 +387,39 @@ func (ps *PostStore) GetUserFeed(ctx context.Context, userID int64, fq FeedPagin
 			&record.CreatedAt,
 			&record.Version,
 			pq.Array(&record.Tags),
+			&record.Visibility,
+			pq.Array(&record.MentionedUserIDs),
 			&record.User.Username,
 			&record.CommentsCount,
+			&reactionCounts,
+			pq.Array(&record.Reactions.Mine),
 		)
 
 		if err != nil {
 			return nil, err
 		}
 
+		// Both aggregates are NULL for posts nobody reacted to.
+		if reactionCounts != nil {
+			if err := json.Unmarshal(reactionCounts, &record.Reactions.Counts); err != nil {
+				return nil, err
+			}
+		}
+
+		if record.Reactions.Mine == nil {
+			record.Reactions.Mine = []string{}
+		}
+
 		feedRecords = append(feedRecords, &record)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if reverse {
+		slices.Reverse(feedRecords)
+	}
+
 	return feedRecords, nil
 }
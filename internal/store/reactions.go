@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidReaction = errors.New("unknown reaction type")
+
+// ReactionTypes is the fixed set of reactions users can leave, each shown
+// to clients as an emoji.
+var ReactionTypes = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+func ValidReaction(reaction string) bool {
+	return slices.Contains(ReactionTypes, reaction)
+}
+
+// ReactionTarget is what a reaction is left on.
+type ReactionTarget string
+
+const (
+	PostReaction    ReactionTarget = "post"
+	CommentReaction ReactionTarget = "comment"
+)
+
+// ReactionSummary holds the per-type counts on a post or comment and the
+// types the viewing user reacted with.
+type ReactionSummary struct {
+	Counts map[string]int `json:"counts"`
+	Mine   []string       `json:"mine"`
+}
+
+func NewReactionSummary() *ReactionSummary {
+	return &ReactionSummary{Counts: map[string]int{}, Mine: []string{}}
+}
+
+type ReactionStore struct {
+	db DBTX
+}
+
+// reactionTable maps a target to its table and id column. Both are fixed
+// identifiers, never user input, so they are safe to format into queries.
+func reactionTable(target ReactionTarget) (table, column string, err error) {
+	switch target {
+	case PostReaction:
+		return "post_reactions", "post_id", nil
+	case CommentReaction:
+		return "comment_reactions", "comment_id", nil
+	default:
+		return "", "", fmt.Errorf("unknown reaction target %q", target)
+	}
+}
+
+// Add records userID's reaction. Reacting twice with the same type is a
+// no-op.
+func (s *ReactionStore) Add(ctx context.Context, target ReactionTarget, targetID, userID int64, reaction string) error {
+	if !ValidReaction(reaction) {
+		return ErrInvalidReaction
+	}
+
+	table, column, err := reactionTable(target)
+
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+	  INSERT INTO %s (%s, user_id, type) VALUES ($1, $2, $3)
+	  ON CONFLICT DO NOTHING
+	`, table, column)
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	_, err = s.db.ExecContext(ctx, query, targetID, userID, reaction)
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// Remove deletes userID's reaction, if there is one.
+func (s *ReactionStore) Remove(ctx context.Context, target ReactionTarget, targetID, userID int64, reaction string) error {
+	if !ValidReaction(reaction) {
+		return ErrInvalidReaction
+	}
+
+	table, column, err := reactionTable(target)
+
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE %s = $1 AND user_id = $2 AND type = $3`, table, column)
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	_, err = s.db.ExecContext(ctx, query, targetID, userID, reaction)
+	return err
+}
+
+// Summaries returns the reactions on each of targetIDs as seen by viewerID.
+// Every requested id gets a summary, empty when nobody reacted.
+func (s *ReactionStore) Summaries(ctx context.Context, target ReactionTarget, targetIDs []int64, viewerID int64) (map[int64]*ReactionSummary, error) {
+	table, column, err := reactionTable(target)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+	  SELECT %[2]s, type, COUNT(*), BOOL_OR(user_id = $2)
+	  FROM %[1]s
+	  WHERE %[2]s = ANY($1)
+	  GROUP BY %[2]s, type
+	  ORDER BY %[2]s, type
+	`, table, column)
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(targetIDs), viewerID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	summaries := make(map[int64]*ReactionSummary, len(targetIDs))
+
+	for _, id := range targetIDs {
+		summaries[id] = NewReactionSummary()
+	}
+
+	for rows.Next() {
+		var (
+			id       int64
+			reaction string
+			count    int
+			mine     bool
+		)
+
+		if err := rows.Scan(&id, &reaction, &count, &mine); err != nil {
+			return nil, err
+		}
+
+		summaries[id].Counts[reaction] = count
+
+		if mine {
+			summaries[id].Mine = append(summaries[id].Mine, reaction)
+		}
+	}
+
+	return summaries, rows.Err()
+}
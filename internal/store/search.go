@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	SearchPosts    = "posts"
+	SearchComments = "comments"
+	SearchUsers    = "users"
+)
+
+type SearchStore struct {
+	db DBTX
+}
+
+type SearchResult struct {
+	Type      string  `json:"type"`
+	ID        int64   `json:"id"`
+	PostID    int64   `json:"post_id,omitempty"`
+	UserID    int64   `json:"user_id"`
+	Username  string  `json:"username"`
+	Title     string  `json:"title,omitempty"`
+	Snippet   string  `json:"snippet"`
+	Rank      float32 `json:"rank"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// SearchQuery pages through search results the same way FeedPaginationQuery
+// pages through the feed, except that results are always ordered by rank.
+type SearchQuery struct {
+	Query  string `json:"q" validate:"required,max=100"`
+	Type   string `json:"type" validate:"oneof=posts comments users"`
+	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Cursor string `json:"cursor" validate:"omitempty,max=512"`
+
+	// Position is the decoded Cursor. When it is nil results fall back to
+	// LIMIT/OFFSET pagination.
+	Position *Cursor `json:"-"`
+
+	// ViewerID is who is searching. Posts, and comments on posts, they may
+	// not see are left out.
+	ViewerID int64 `json:"-"`
+}
+
+func (sq SearchQuery) Parse(r *http.Request) (SearchQuery, error) {
+	qs := r.URL.Query()
+
+	sq.Query = strings.TrimSpace(qs.Get("q"))
+
+	if t := qs.Get("type"); t != "" {
+		sq.Type = t
+	}
+
+	limit := qs.Get("limit")
+
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return sq, &ParamError{Param: "limit", Value: limit, Expected: "an integer"}
+		}
+
+		sq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+
+		if err != nil {
+			return sq, &ParamError{Param: "offset", Value: offset, Expected: "an integer"}
+		}
+
+		sq.Offset = o
+	}
+
+	if cursor := qs.Get("cursor"); cursor != "" {
+		sq.Cursor = cursor
+	}
+
+	return sq, nil
+}
+
+// ts_headline wraps matches in these control characters rather than in
+// markup, since the documents are user input; Snippet escapes the rest and
+// turns them into <mark> tags. They are stripped from the documents first so
+// nobody can forge them.
+const (
+	startSel = "\x02"
+	stopSel  = "\x03"
+)
+
+// headlineOptions controls the ts_headline snippets.
+const headlineOptions = `StartSel="` + startSel + `", StopSel="` + stopSel + `", MaxWords=35, MinWords=15, MaxFragments=2`
+
+// headline is the ts_headline of the text %[1]s against the query q.
+const headline = `ts_headline('%[2]s', translate(%[1]s, chr(2) || chr(3), ''), q, '` + headlineOptions + `')`
+
+var snippetReplacer = strings.NewReplacer(startSel, "<mark>", stopSel, "</mark>")
+
+// Snippet turns a headline into HTML: the text is escaped and only the
+// highlighted matches are wrapped in <mark> tags.
+func Snippet(headline string) string {
+	return snippetReplacer.Replace(html.EscapeString(headline))
+}
+
+// Each query selects id, post_id, user_id, username, title, snippet, rank and
+// created_at for documents matching the text search query or, for fuzzy
+// matches, sharing enough trigrams with the raw input.
+var searchQueries = map[string]string{
+	SearchPosts: `
+		SELECT p.id AS id, p.id AS post_id, p.user_id AS user_id, u.username AS username, p.title AS title,
+		  ` + fmt.Sprintf(headline, "p.content", "english") + ` AS snippet,
+		  GREATEST(ts_rank(p.search_vector, q), word_similarity($1, p.title)) AS rank,
+		  p.created_at AS created_at
+		FROM posts p
+		JOIN users u ON u.id = p.user_id,
+		  websearch_to_tsquery('english', $1) q
+		WHERE p.search_vector @@ q OR $1 <% p.title`,
+	SearchComments: `
+		SELECT c.id AS id, c.post_id AS post_id, c.user_id AS user_id, u.username AS username, '' AS title,
+		  ` + fmt.Sprintf(headline, "c.content", "english") + ` AS snippet,
+		  GREATEST(ts_rank(c.search_vector, q), word_similarity($1, c.content)) AS rank,
+		  c.created_at AS created_at
+		FROM comments c
+		JOIN users u ON u.id = c.user_id,
+		  websearch_to_tsquery('english', $1) q
+		WHERE c.deleted_at IS NULL AND (c.search_vector @@ q OR $1 <% c.content)`,
+	SearchUsers: `
+		SELECT u.id AS id, 0::bigint AS post_id, u.id AS user_id, u.username AS username, '' AS title,
+		  ` + fmt.Sprintf(headline, "u.username", "simple") + ` AS snippet,
+		  GREATEST(ts_rank(u.search_vector, q), similarity(u.username, $1)) AS rank,
+		  u.created_at AS created_at
+		FROM users u,
+		  websearch_to_tsquery('simple', $1) q
+		WHERE u.search_vector @@ q OR u.username % $1`,
+}
+
+func (s *SearchStore) Search(ctx context.Context, sq SearchQuery) ([]*SearchResult, error) {
+	inner, ok := searchQueries[sq.Type]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown search type %q", sq.Type)
+	}
+
+	args := []any{sq.Query, sq.Limit}
+	where := "TRUE"
+	order := "DESC"
+	page := " LIMIT $2"
+	reverse := false
+
+	// Both post and comment results carry the post they belong to, which
+	// must be visible and not in the trash. Like in the feed and the comment
+	// listings, nothing written by someone on the other side of a block
+	// shows up.
+	if sq.Type != SearchUsers {
+		args = append(args, sq.ViewerID)
+		viewer := fmt.Sprintf("$%d::bigint", len(args))
+
+		where = `EXISTS (
+			SELECT 1 FROM posts vp
+			WHERE vp.id = s.post_id AND vp.deleted_at IS NULL
+			  AND NOT ` + fmt.Sprintf(blockedBetween, viewer, "vp.user_id") + `
+			  AND ` + fmt.Sprintf(postVisibleTo, "vp", viewer) + `
+		) AND NOT ` + fmt.Sprintf(blockedBetween, viewer, "s.user_id")
+	}
+
+	if sq.Position != nil {
+		var op string
+		op, order, reverse = sq.Position.keyset()
+
+		args = append(args, sq.Position.Rank, sq.Position.ID)
+		where += fmt.Sprintf(" AND (s.rank, s.id) %s ($%d::real, $%d)", op, len(args)-1, len(args))
+	} else {
+		args = append(args, sq.Offset)
+		page += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	query := `
+	SELECT s.* FROM (` + inner + `
+	) s
+	WHERE ` + where + `
+	ORDER BY s.rank ` + order + `, s.id ` + order + page
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	results := []*SearchResult{}
+
+	for rows.Next() {
+		result := &SearchResult{Type: sq.Type}
+
+		err := rows.Scan(
+			&result.ID,
+			&result.PostID,
+			&result.UserID,
+			&result.Username,
+			&result.Title,
+			&result.Snippet,
+			&result.Rank,
+			&result.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.Snippet = Snippet(result.Snippet)
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if reverse {
+		slices.Reverse(results)
+	}
+
+	return results, nil
+}
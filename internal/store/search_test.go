@@ -0,0 +1,40 @@
+package store
+
+import "testing"
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		headline string
+		want     string
+	}{
+		{
+			name:     "plain text",
+			headline: "nothing to see",
+			want:     "nothing to see",
+		},
+		{
+			name:     "highlighted match",
+			headline: "a " + startSel + "match" + stopSel + " here",
+			want:     "a <mark>match</mark> here",
+		},
+		{
+			name:     "markup in the document",
+			headline: `<script>alert("` + startSel + "hi" + stopSel + `")</script>`,
+			want:     `&lt;script&gt;alert(&#34;<mark>hi</mark>&#34;)&lt;/script&gt;`,
+		},
+		{
+			name:     "mark tags in the document",
+			headline: "<mark>fake</mark>",
+			want:     "&lt;mark&gt;fake&lt;/mark&gt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snippet(tt.headline); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
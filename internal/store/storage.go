@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -11,34 +12,150 @@ var (
 	QUERY_TIMEOUT_DURATION = time.Second * 5
 	ErrNotFound            = errors.New("resource not found")
 	ErrConflict            = errors.New("resource already exists")
+	ErrVersionConflict     = errors.New("resource has been modified")
 )
 
+// DBTX is the part of *sql.DB and *sql.Tx the stores use, so the same store
+// code runs standalone or inside a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type Storage struct {
 	Posts interface {
 		Create(context.Context, *Post) error
 		GetByID(context.Context, int64) (*Post, error)
-		DeleteByID(ctx context.Context, postID int64) error
+		DeleteByID(ctx context.Context, postID, deletedBy int64) error
+		Restore(ctx context.Context, postID, userID int64, retention time.Duration) error
 		UpdateByID(context.Context, *Post) error
 		GetUserFeed(context.Context, int64, FeedPaginationQuery) ([]*FeedRecord, error)
+		CreateBatch(context.Context, []*Post) error
 	}
 	Users interface {
 		Create(context.Context, *User) error
 		GetByID(context.Context, int64) (*User, error)
+		GetByEmail(context.Context, string) (*User, error)
+		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error
+		Activate(ctx context.Context, token string) error
+		SetPrivate(ctx context.Context, userID int64, private bool) error
+		CreateBatch(context.Context, []*User) error
 	}
 	Comments interface {
-		GetByPostID(ctx context.Context, postID int64) (*[]Comment, error)
+		Create(context.Context, *Comment) error
+		GetByID(context.Context, int64) (*Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64) (*[]Comment, error)
+		UpdateByID(context.Context, *Comment) error
+		DeleteByID(ctx context.Context, commentID, deletedBy int64) error
+		Restore(ctx context.Context, postID, commentID, userID int64, retention time.Duration) error
+		CreateBatch(context.Context, []*Comment) error
 	}
 	Followers interface {
-		Follow(ctx context.Context, followedID, userID int64) error
+		Follow(ctx context.Context, followedID, userID int64) (requested bool, err error)
 		Unfollow(ctx context.Context, unfollowedID, userID int64) error
+		FollowBatch(context.Context, []Follower) error
+		List(ctx context.Context, list string, userID, viewerID int64, fq FollowListQuery) ([]*FollowListEntry, error)
+		Stats(ctx context.Context, userID, viewerID int64) (*FollowStats, error)
+		IsFollowing(ctx context.Context, userID, targetID int64) (bool, error)
+		Approve(ctx context.Context, targetID, requesterID int64) error
+		Reject(ctx context.Context, targetID, requesterID int64) error
+	}
+	Roles interface {
+		GetByName(context.Context, string) (*Role, error)
+	}
+	Search interface {
+		Search(context.Context, SearchQuery) ([]*SearchResult, error)
+	}
+	Reactions interface {
+		Add(ctx context.Context, target ReactionTarget, targetID, userID int64, reaction string) error
+		Remove(ctx context.Context, target ReactionTarget, targetID, userID int64, reaction string) error
+		Summaries(ctx context.Context, target ReactionTarget, targetIDs []int64, viewerID int64) (map[int64]*ReactionSummary, error)
+	}
+	Blocks interface {
+		Block(ctx context.Context, blockerID, blockedID int64) error
+		Unblock(ctx context.Context, blockerID, blockedID int64) error
+		Mute(ctx context.Context, muterID, mutedID int64) error
+		Unmute(ctx context.Context, muterID, mutedID int64) error
+		IsBlocked(ctx context.Context, userID, otherID int64) (bool, error)
+	}
+	Trash interface {
+		List(ctx context.Context, userID int64, retention time.Duration, tq TrashQuery) ([]*TrashItem, error)
+		Purge(ctx context.Context, retention time.Duration) (posts, comments int64, err error)
+	}
+	Tx interface {
+		// WithTx runs fn with a Storage whose stores all share one
+		// transaction. It commits when fn returns nil and rolls back when fn
+		// returns an error or panics.
+		WithTx(ctx context.Context, fn func(Storage) error) error
 	}
 }
 
-func NewStorage(db *sql.DB) Storage {
+// NewStorage builds the Postgres stores on top of db, which is either a
+// *sql.DB or a *sql.Tx.
+func NewStorage(db DBTX) Storage {
 	return Storage{
 		Posts:     &PostStore{db},
 		Users:     &UserStore{db},
 		Comments:  &CommentStore{db},
 		Followers: &FollowerStore{db},
+		Roles:     &RoleStore{db},
+		Search:    &SearchStore{db},
+		Reactions: &ReactionStore{db},
+		Blocks:    &BlockStore{db},
+		Trash:     &TrashStore{db},
+		Tx:        &TxStore{db},
 	}
 }
+
+type TxStore struct {
+	db DBTX
+}
+
+func (s *TxStore) WithTx(ctx context.Context, fn func(Storage) error) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(NewStorage(tx))
+	})
+}
+
+// WithTx runs fn inside a transaction on db. The transaction is committed
+// when fn returns nil and rolled back when it returns an error or panics.
+func WithTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	return withTx(ctx, db, fn)
+}
+
+// withTx starts a transaction on db, or joins the one db already is, so
+// store methods that need a transaction compose with an outer one.
+func withTx(ctx context.Context, db DBTX, fn func(*sql.Tx) error) (err error) {
+	if tx, ok := db.(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	beginner, ok := db.(interface {
+		BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
+	})
+
+	if !ok {
+		return fmt.Errorf("store: %T cannot begin transactions", db)
+	}
+
+	tx, err := beginner.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
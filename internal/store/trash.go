@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	TrashPost    = "post"
+	TrashComment = "comment"
+)
+
+// inRetention is true when the timestamp %[1]s is less than %[2]s seconds
+// ago.
+const inRetention = `%[1]s > NOW() - %[2]s * INTERVAL '1 second'`
+
+// deletedWithParent is true when the comment aliased %[1]s went to the trash
+// together with its parent.
+const deletedWithParent = `EXISTS (
+	SELECT 1 FROM comments dp
+	WHERE dp.id = %[1]s.parent_id AND dp.deleted_at = %[1]s.deleted_at
+)`
+
+// TrashItem is a post or comment in its author's trash. DeletedBy tells
+// whether the author deleted it or a moderator did. Replies deleted along
+// with their parent are not listed; they come back with it.
+type TrashItem struct {
+	Type      string `json:"type"`
+	ID        int64  `json:"id"`
+	PostID    int64  `json:"post_id"`
+	UserID    int64  `json:"user_id"`
+	DeletedBy int64  `json:"deleted_by"`
+	Title     string `json:"title,omitempty"`
+	Content   string `json:"content"`
+	DeletedAt string `json:"deleted_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// TrashQuery pages through a trash, most recently deleted first.
+type TrashQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=50"`
+	Offset int `json:"offset" validate:"gte=0"`
+}
+
+func (tq TrashQuery) Parse(r *http.Request) (TrashQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return tq, &ParamError{Param: "limit", Value: limit, Expected: "an integer"}
+		}
+
+		tq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+
+		if err != nil {
+			return tq, &ParamError{Param: "offset", Value: offset, Expected: "an integer"}
+		}
+
+		tq.Offset = o
+	}
+
+	return tq, nil
+}
+
+type TrashStore struct {
+	db DBTX
+}
+
+// List returns the posts and comments of userID deleted less than retention
+// ago, whoever deleted them.
+func (s *TrashStore) List(ctx context.Context, userID int64, retention time.Duration, tq TrashQuery) ([]*TrashItem, error) {
+	query := `
+	  SELECT t.type, t.id, t.post_id, t.user_id, COALESCE(t.deleted_by, 0), t.title, t.content, t.deleted_at,
+	    t.deleted_at + $2 * INTERVAL '1 second' AS expires_at
+	  FROM (
+	    SELECT 'post' AS type, p.id, p.id AS post_id, p.user_id, p.deleted_by, p.title, p.content, p.deleted_at
+	    FROM posts p
+	    WHERE p.user_id = $1 AND ` + fmt.Sprintf(inRetention, "p.deleted_at", "$2") + `
+	    UNION ALL
+	    SELECT 'comment', c.id, c.post_id, c.user_id, c.deleted_by, '', c.content, c.deleted_at
+	    FROM comments c
+	    WHERE c.user_id = $1 AND ` + fmt.Sprintf(inRetention, "c.deleted_at", "$2") + `
+	      AND NOT ` + fmt.Sprintf(deletedWithParent, "c") + `
+	  ) t
+	  ORDER BY t.deleted_at DESC, t.type DESC, t.id DESC
+	  LIMIT $3 OFFSET $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, retention.Seconds(), tq.Limit, tq.Offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	items := []*TrashItem{}
+
+	for rows.Next() {
+		var item TrashItem
+
+		err := rows.Scan(
+			&item.Type,
+			&item.ID,
+			&item.PostID,
+			&item.UserID,
+			&item.DeletedBy,
+			&item.Title,
+			&item.Content,
+			&item.DeletedAt,
+			&item.ExpiresAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, &item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Purge permanently removes the posts and comments deleted more than
+// retention ago. The foreign keys take the comments of purged posts, the
+// replies of purged comments and the reactions on both with them.
+func (s *TrashStore) Purge(ctx context.Context, retention time.Duration) (posts, comments int64, err error) {
+	err = withTx(ctx, s.db, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+		defer cancel()
+
+		expired := `deleted_at IS NOT NULL AND NOT ` + fmt.Sprintf(inRetention, "deleted_at", "$1")
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE `+expired, retention.Seconds())
+
+		if err != nil {
+			return err
+		}
+
+		if posts, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		res, err = tx.ExecContext(ctx, `DELETE FROM comments WHERE `+expired, retention.Seconds())
+
+		if err != nil {
+			return err
+		}
+
+		comments, err = res.RowsAffected()
+		return err
+	})
+
+	return posts, comments, err
+}
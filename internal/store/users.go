@@ -2,52 +2,160 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrDuplicateEmail    = errors.New("a user with that email already exists")
+	ErrDuplicateUsername = errors.New("a user with that username already exists")
 )
 
 type UserStore struct {
-	db *sql.DB
+	db DBTX
 }
 
 type User struct {
-	ID        int64  `json:"id"`
-	Username  string `json:"username"`
-	Email     string `json:"email"`
-	Password  string `json:"-"`
-	CreatedAt string `json:"created_at"`
+	ID        int64    `json:"id"`
+	Username  string   `json:"username"`
+	Email     string   `json:"email"`
+	Password  password `json:"-"`
+	IsActive  bool     `json:"is_active"`
+	IsPrivate bool     `json:"is_private"`
+	RoleID    int64    `json:"role_id"`
+	Role      Role     `json:"role"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type password struct {
+	text *string
+	hash []byte
+}
+
+func (p *password) Set(text string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(text), bcrypt.DefaultCost)
+
+	if err != nil {
+		return err
+	}
+
+	p.text = &text
+	p.hash = hash
+
+	return nil
+}
+
+func (p *password) Compare(text string) error {
+	return bcrypt.CompareHashAndPassword(p.hash, []byte(text))
 }
 
 func (s *UserStore) Create(ctx context.Context, user *User) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, user)
+	})
+}
+
+func (s *UserStore) create(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
-	  INSERT INTO users (username, password, email)
-	  VALUES ($1, $2, $3) RETURNING id, created_at
+	  INSERT INTO users (username, password, email, is_private, role_id)
+	  VALUES ($1, $2, $3, $4, (SELECT id FROM roles WHERE name = $5))
+	  RETURNING id, role_id, created_at
 	`
 	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
+	role := user.Role.Name
+
+	if role == "" {
+		role = "user"
+	}
+
+	err := tx.QueryRowContext(
 		ctx,
 		query,
 		user.Username,
-		user.Password,
+		user.Password.hash,
 		user.Email,
+		user.IsPrivate,
+		role,
 	).Scan(
 		&user.ID,
+		&user.RoleID,
 		&user.CreatedAt,
 	)
 
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			switch pqErr.Constraint {
+			case "users_email_key":
+				return ErrDuplicateEmail
+			case "users_username_key":
+				return ErrDuplicateUsername
+			}
+		}
+
 		return err
 	}
 
 	return nil
 }
 
+// CreateBatch inserts many users in one transaction. It is meant for seeding
+// and, unlike Create, honours IsActive and CreatedAt.
+func (s *UserStore) CreateBatch(ctx context.Context, users []*User) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		ids, err := reserveIDs(ctx, tx, "users_id_seq", len(users))
+
+		if err != nil {
+			return err
+		}
+
+		roles, err := roleIDs(ctx, tx)
+
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]any, len(users))
+
+		for i, user := range users {
+			role := user.Role.Name
+
+			if role == "" {
+				role = "user"
+			}
+
+			roleID, ok := roles[role]
+
+			if !ok {
+				return fmt.Errorf("unknown role %q", role)
+			}
+
+			user.ID = ids[i]
+			user.RoleID = roleID
+			rows[i] = []any{user.ID, user.Username, user.Password.hash, user.Email, user.IsActive, user.IsPrivate, roleID, timeOrNow(user.CreatedAt)}
+		}
+
+		columns := []string{"id", "username", "password", "email", "is_active", "is_private", "role_id", "created_at"}
+
+		return batchInsert(ctx, tx, "users", columns, rows, "")
+	})
+}
+
 func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	query := `
-	  SELECT id, username, email, password, created_at
-	  FROM users
-	  WHERE ID = $1
+	  SELECT u.id, u.username, u.email, u.password, u.is_active, u.is_private, u.created_at,
+	    r.id, r.name, r.level, r.description
+	  FROM users u
+	  JOIN roles r ON r.id = u.role_id
+	  WHERE u.id = $1
 	`
 	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
 	defer cancel()
@@ -62,8 +170,14 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 		&user.ID,
 		&user.Username,
 		&user.Email,
-		&user.Password,
+		&user.Password.hash,
+		&user.IsActive,
+		&user.IsPrivate,
 		&user.CreatedAt,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Level,
+		&user.Role.Description,
 	)
 
 	if err != nil {
@@ -75,5 +189,195 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 		}
 	}
 
+	user.RoleID = user.Role.ID
+
 	return user, nil
 }
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `
+	  SELECT u.id, u.username, u.email, u.password, u.is_active, u.is_private, u.created_at,
+	    r.id, r.name, r.level, r.description
+	  FROM users u
+	  JOIN roles r ON r.id = u.role_id
+	  WHERE u.email = $1 AND u.is_active = true
+	`
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	user := &User{}
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		email,
+	).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Password.hash,
+		&user.IsActive,
+		&user.IsPrivate,
+		&user.CreatedAt,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Level,
+		&user.Role.Description,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	user.RoleID = user.Role.ID
+
+	return user, nil
+}
+
+func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		return s.createUserInvitation(ctx, tx, token, invitationExp, user.ID)
+	})
+}
+
+func (s *UserStore) Activate(ctx context.Context, token string) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		user, err := s.getUserFromInvitation(ctx, tx, token)
+
+		if err != nil {
+			return err
+		}
+
+		user.IsActive = true
+
+		if err := s.update(ctx, tx, user); err != nil {
+			return err
+		}
+
+		return s.deleteUserInvitations(ctx, tx, user.ID)
+	})
+}
+
+// SetPrivate switches the account between private and public. Going public
+// approves every pending follow request, since nobody is left to answer them.
+func (s *UserStore) SetPrivate(ctx context.Context, userID int64, private bool) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `UPDATE users SET is_private = $1 WHERE id = $2`, private, userID)
+
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if private {
+			return nil
+		}
+
+		query := `
+		  INSERT INTO followers (user_id, follower_id)
+		  SELECT requester_id, target_id FROM follow_requests WHERE target_id = $1
+		  ON CONFLICT DO NOTHING
+		`
+
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE target_id = $1`, userID)
+		return err
+	})
+}
+
+func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token string, exp time.Duration, userID int64) error {
+	query := `
+	  INSERT INTO user_invitations (token, user_id, expiry)
+	  VALUES ($1, $2, $3)
+	`
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, hashToken(token), userID, time.Now().Add(exp))
+	return err
+}
+
+func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+	  SELECT u.id, u.username, u.email, u.created_at, u.is_active
+	  FROM users u
+	  JOIN user_invitations ui ON u.id = ui.user_id
+	  WHERE ui.token = $1 AND ui.expiry > $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	user := &User{}
+
+	err := tx.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `
+	  UPDATE users SET username = $1, email = $2, is_active = $3
+	  WHERE id = $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, user.Username, user.Email, user.IsActive, user.ID)
+	return err
+}
+
+func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM user_invitations WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QUERY_TIMEOUT_DURATION)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
+// hashToken stores only a digest of the activation token so a leaked
+// invitations table can't be used to activate accounts.
+func hashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return []byte(hex.EncodeToString(hash[:]))
+}
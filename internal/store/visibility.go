@@ -0,0 +1,69 @@
+package store
+
+import (
+	"regexp"
+	"slices"
+)
+
+// Post visibility levels. Authors always see their own posts, and nobody
+// else sees past these levels, moderators and admins included: a post
+// hidden from the feed and search is hidden from GET as well.
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityMentioned = "mentioned"
+	VisibilityPrivate   = "private"
+)
+
+var mentionPattern = regexp.MustCompile(`@(\w+)`)
+
+// Mentions returns the distinct usernames content mentions as @username.
+func Mentions(content string) []string {
+	var names []string
+
+	for _, m := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		if !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+
+	return names
+}
+
+// postVisibleTo is true when the post aliased %[1]s may be seen by the user
+// whose id is %[2]s. Public posts of private accounts are limited to their
+// approved followers, as are followers-only posts; mentioned-only posts go to
+// the users they mention, and when the account is private only to those who
+// also follow it.
+const postVisibleTo = `(
+	%[1]s.user_id = %[2]s
+	OR (%[1]s.visibility = 'public' AND NOT EXISTS (
+		SELECT 1 FROM users au WHERE au.id = %[1]s.user_id AND au.is_private
+	))
+	OR (%[1]s.visibility IN ('public', 'followers') AND EXISTS (
+		SELECT 1 FROM followers vf WHERE vf.user_id = %[2]s AND vf.follower_id = %[1]s.user_id
+	))
+	OR (%[1]s.visibility = 'mentioned' AND %[2]s = ANY(%[1]s.mentioned_user_ids) AND (
+		NOT EXISTS (SELECT 1 FROM users au WHERE au.id = %[1]s.user_id AND au.is_private)
+		OR EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = %[2]s AND vf.follower_id = %[1]s.user_id)
+	))
+)`
+
+// VisibleTo is the counterpart of postVisibleTo for a post already in
+// memory. following tells whether viewerID follows the author.
+func (p *Post) VisibleTo(viewerID int64, authorPrivate, following bool) bool {
+	if p.UserID == viewerID {
+		return true
+	}
+
+	switch p.Visibility {
+	case VisibilityPublic, "":
+		return !authorPrivate || following
+	case VisibilityFollowers:
+		return following
+	case VisibilityMentioned:
+		return (!authorPrivate || following) && slices.Contains(p.MentionedUserIDs, viewerID)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,459 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RowsKey is the number of rows a store call read or wrote.
+const RowsKey = attribute.Key("db.response.rows")
+
+// InstrumentStorage wraps every store of s so that each method call gets a
+// client span, a child of the request span in ctx. Like the metrics
+// decorator, it also instruments the storages handed out by Tx.WithTx.
+func InstrumentStorage(s store.Storage) store.Storage {
+	return store.Storage{
+		Posts:     &postStore{s},
+		Users:     &userStore{s},
+		Comments:  &commentStore{s},
+		Followers: &followerStore{s},
+		Roles:     &roleStore{s},
+		Search:    &searchStore{s},
+		Reactions: &reactionStore{s},
+		Blocks:    &blockStore{s},
+		Trash:     &trashStore{s},
+		Tx:        &txStore{s},
+	}
+}
+
+func startSpan(ctx context.Context, storeName, method, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, storeName+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBCollectionName(storeName),
+			semconv.DBOperationName(operation),
+		),
+	)
+}
+
+// rowsUnknown is passed to endSpan by the calls that don't learn how many
+// rows they touched: deletes that cascade and writes that may be no-ops.
+const rowsUnknown = -1
+
+// endSpan records the outcome of a store call and the rows it touched.
+// ErrNotFound is an answer, not a failure, so it doesn't mark the span.
+func endSpan(span trace.Span, err error, rows int) {
+	defer span.End()
+
+	if err != nil {
+		rows = 0
+	}
+
+	if rows != rowsUnknown {
+		span.SetAttributes(RowsKey.Int(rows))
+	}
+
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+type postStore struct {
+	next store.Storage
+}
+
+func (s *postStore) Create(ctx context.Context, post *store.Post) error {
+	ctx, span := startSpan(ctx, "posts", "Create", "INSERT")
+	err := s.next.Posts.Create(ctx, post)
+	endSpan(span, err, 1)
+
+	return err
+}
+
+func (s *postStore) GetByID(ctx context.Context, postID int64) (*store.Post, error) {
+	ctx, span := startSpan(ctx, "posts", "GetByID", "SELECT")
+	post, err := s.next.Posts.GetByID(ctx, postID)
+	endSpan(span, err, 1)
+
+	return post, err
+}
+
+func (s *postStore) DeleteByID(ctx context.Context, postID, deletedBy int64) error {
+	ctx, span := startSpan(ctx, "posts", "DeleteByID", "UPDATE")
+	err := s.next.Posts.DeleteByID(ctx, postID, deletedBy)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *postStore) Restore(ctx context.Context, postID, userID int64, retention time.Duration) error {
+	ctx, span := startSpan(ctx, "posts", "Restore", "UPDATE")
+	err := s.next.Posts.Restore(ctx, postID, userID, retention)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *postStore) UpdateByID(ctx context.Context, post *store.Post) error {
+	ctx, span := startSpan(ctx, "posts", "UpdateByID", "UPDATE")
+	err := s.next.Posts.UpdateByID(ctx, post)
+	endSpan(span, err, 1)
+
+	return err
+}
+
+func (s *postStore) GetUserFeed(ctx context.Context, userID int64, fq store.FeedPaginationQuery) ([]*store.FeedRecord, error) {
+	ctx, span := startSpan(ctx, "posts", "GetUserFeed", "SELECT")
+	feed, err := s.next.Posts.GetUserFeed(ctx, userID, fq)
+	endSpan(span, err, len(feed))
+
+	return feed, err
+}
+
+func (s *postStore) CreateBatch(ctx context.Context, posts []*store.Post) error {
+	ctx, span := startSpan(ctx, "posts", "CreateBatch", "INSERT")
+	err := s.next.Posts.CreateBatch(ctx, posts)
+	endSpan(span, err, len(posts))
+
+	return err
+}
+
+type userStore struct {
+	next store.Storage
+}
+
+func (s *userStore) Create(ctx context.Context, user *store.User) error {
+	ctx, span := startSpan(ctx, "users", "Create", "INSERT")
+	err := s.next.Users.Create(ctx, user)
+	endSpan(span, err, 1)
+
+	return err
+}
+
+func (s *userStore) GetByID(ctx context.Context, userID int64) (*store.User, error) {
+	ctx, span := startSpan(ctx, "users", "GetByID", "SELECT")
+	user, err := s.next.Users.GetByID(ctx, userID)
+	endSpan(span, err, 1)
+
+	return user, err
+}
+
+func (s *userStore) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	ctx, span := startSpan(ctx, "users", "GetByEmail", "SELECT")
+	user, err := s.next.Users.GetByEmail(ctx, email)
+	endSpan(span, err, 1)
+
+	return user, err
+}
+
+func (s *userStore) CreateAndInvite(ctx context.Context, user *store.User, token string, invitationExp time.Duration) error {
+	ctx, span := startSpan(ctx, "users", "CreateAndInvite", "INSERT")
+	err := s.next.Users.CreateAndInvite(ctx, user, token, invitationExp)
+	endSpan(span, err, 2)
+
+	return err
+}
+
+func (s *userStore) Activate(ctx context.Context, token string) error {
+	ctx, span := startSpan(ctx, "users", "Activate", "UPDATE")
+	err := s.next.Users.Activate(ctx, token)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *userStore) CreateBatch(ctx context.Context, users []*store.User) error {
+	ctx, span := startSpan(ctx, "users", "CreateBatch", "INSERT")
+	err := s.next.Users.CreateBatch(ctx, users)
+	endSpan(span, err, len(users))
+
+	return err
+}
+
+func (s *userStore) SetPrivate(ctx context.Context, userID int64, private bool) error {
+	ctx, span := startSpan(ctx, "users", "SetPrivate", "UPDATE")
+	err := s.next.Users.SetPrivate(ctx, userID, private)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+type commentStore struct {
+	next store.Storage
+}
+
+func (s *commentStore) Create(ctx context.Context, comment *store.Comment) error {
+	ctx, span := startSpan(ctx, "comments", "Create", "INSERT")
+	err := s.next.Comments.Create(ctx, comment)
+	endSpan(span, err, 1)
+
+	return err
+}
+
+func (s *commentStore) GetByID(ctx context.Context, commentID int64) (*store.Comment, error) {
+	ctx, span := startSpan(ctx, "comments", "GetByID", "SELECT")
+	comment, err := s.next.Comments.GetByID(ctx, commentID)
+	endSpan(span, err, 1)
+
+	return comment, err
+}
+
+func (s *commentStore) GetByPostID(ctx context.Context, postID, viewerID int64) (*[]store.Comment, error) {
+	ctx, span := startSpan(ctx, "comments", "GetByPostID", "SELECT")
+	comments, err := s.next.Comments.GetByPostID(ctx, postID, viewerID)
+
+	rows := 0
+
+	if comments != nil {
+		rows = len(*comments)
+	}
+
+	endSpan(span, err, rows)
+
+	return comments, err
+}
+
+func (s *commentStore) UpdateByID(ctx context.Context, comment *store.Comment) error {
+	ctx, span := startSpan(ctx, "comments", "UpdateByID", "UPDATE")
+	err := s.next.Comments.UpdateByID(ctx, comment)
+	endSpan(span, err, 1)
+
+	return err
+}
+
+func (s *commentStore) DeleteByID(ctx context.Context, commentID, deletedBy int64) error {
+	ctx, span := startSpan(ctx, "comments", "DeleteByID", "UPDATE")
+	err := s.next.Comments.DeleteByID(ctx, commentID, deletedBy)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *commentStore) Restore(ctx context.Context, postID, commentID, userID int64, retention time.Duration) error {
+	ctx, span := startSpan(ctx, "comments", "Restore", "UPDATE")
+	err := s.next.Comments.Restore(ctx, postID, commentID, userID, retention)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *commentStore) CreateBatch(ctx context.Context, comments []*store.Comment) error {
+	ctx, span := startSpan(ctx, "comments", "CreateBatch", "INSERT")
+	err := s.next.Comments.CreateBatch(ctx, comments)
+	endSpan(span, err, len(comments))
+
+	return err
+}
+
+type followerStore struct {
+	next store.Storage
+}
+
+func (s *followerStore) Follow(ctx context.Context, followedID, userID int64) (bool, error) {
+	ctx, span := startSpan(ctx, "followers", "Follow", "INSERT")
+	requested, err := s.next.Followers.Follow(ctx, followedID, userID)
+	endSpan(span, err, rowsUnknown)
+
+	return requested, err
+}
+
+func (s *followerStore) Unfollow(ctx context.Context, unfollowedID, userID int64) error {
+	ctx, span := startSpan(ctx, "followers", "Unfollow", "DELETE")
+	err := s.next.Followers.Unfollow(ctx, unfollowedID, userID)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *followerStore) FollowBatch(ctx context.Context, follows []store.Follower) error {
+	ctx, span := startSpan(ctx, "followers", "FollowBatch", "INSERT")
+	err := s.next.Followers.FollowBatch(ctx, follows)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *followerStore) List(ctx context.Context, list string, userID, viewerID int64, fq store.FollowListQuery) ([]*store.FollowListEntry, error) {
+	ctx, span := startSpan(ctx, "followers", "List", "SELECT")
+	entries, err := s.next.Followers.List(ctx, list, userID, viewerID, fq)
+	endSpan(span, err, len(entries))
+
+	return entries, err
+}
+
+func (s *followerStore) Stats(ctx context.Context, userID, viewerID int64) (*store.FollowStats, error) {
+	ctx, span := startSpan(ctx, "followers", "Stats", "SELECT")
+	stats, err := s.next.Followers.Stats(ctx, userID, viewerID)
+	endSpan(span, err, 1)
+
+	return stats, err
+}
+
+func (s *followerStore) IsFollowing(ctx context.Context, userID, targetID int64) (bool, error) {
+	ctx, span := startSpan(ctx, "followers", "IsFollowing", "SELECT")
+	following, err := s.next.Followers.IsFollowing(ctx, userID, targetID)
+	endSpan(span, err, 1)
+
+	return following, err
+}
+
+func (s *followerStore) Approve(ctx context.Context, targetID, requesterID int64) error {
+	ctx, span := startSpan(ctx, "followers", "Approve", "INSERT")
+	err := s.next.Followers.Approve(ctx, targetID, requesterID)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *followerStore) Reject(ctx context.Context, targetID, requesterID int64) error {
+	ctx, span := startSpan(ctx, "followers", "Reject", "DELETE")
+	err := s.next.Followers.Reject(ctx, targetID, requesterID)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+type roleStore struct {
+	next store.Storage
+}
+
+func (s *roleStore) GetByName(ctx context.Context, name string) (*store.Role, error) {
+	ctx, span := startSpan(ctx, "roles", "GetByName", "SELECT")
+	role, err := s.next.Roles.GetByName(ctx, name)
+	endSpan(span, err, 1)
+
+	return role, err
+}
+
+type searchStore struct {
+	next store.Storage
+}
+
+func (s *searchStore) Search(ctx context.Context, q store.SearchQuery) ([]*store.SearchResult, error) {
+	ctx, span := startSpan(ctx, "search", "Search", "SELECT")
+	results, err := s.next.Search.Search(ctx, q)
+	endSpan(span, err, len(results))
+
+	return results, err
+}
+
+type reactionStore struct {
+	next store.Storage
+}
+
+func (s *reactionStore) Add(ctx context.Context, target store.ReactionTarget, targetID, userID int64, reaction string) error {
+	ctx, span := startSpan(ctx, "reactions", "Add", "INSERT")
+	err := s.next.Reactions.Add(ctx, target, targetID, userID, reaction)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *reactionStore) Remove(ctx context.Context, target store.ReactionTarget, targetID, userID int64, reaction string) error {
+	ctx, span := startSpan(ctx, "reactions", "Remove", "DELETE")
+	err := s.next.Reactions.Remove(ctx, target, targetID, userID, reaction)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *reactionStore) Summaries(ctx context.Context, target store.ReactionTarget, targetIDs []int64, viewerID int64) (map[int64]*store.ReactionSummary, error) {
+	ctx, span := startSpan(ctx, "reactions", "Summaries", "SELECT")
+	summaries, err := s.next.Reactions.Summaries(ctx, target, targetIDs, viewerID)
+	endSpan(span, err, len(summaries))
+
+	return summaries, err
+}
+
+type blockStore struct {
+	next store.Storage
+}
+
+func (s *blockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	ctx, span := startSpan(ctx, "blocks", "Block", "INSERT")
+	err := s.next.Blocks.Block(ctx, blockerID, blockedID)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *blockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	ctx, span := startSpan(ctx, "blocks", "Unblock", "DELETE")
+	err := s.next.Blocks.Unblock(ctx, blockerID, blockedID)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *blockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	ctx, span := startSpan(ctx, "blocks", "IsBlocked", "SELECT")
+	blocked, err := s.next.Blocks.IsBlocked(ctx, userID, otherID)
+	endSpan(span, err, 1)
+
+	return blocked, err
+}
+
+func (s *blockStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	ctx, span := startSpan(ctx, "blocks", "Mute", "INSERT")
+	err := s.next.Blocks.Mute(ctx, muterID, mutedID)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+func (s *blockStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	ctx, span := startSpan(ctx, "blocks", "Unmute", "DELETE")
+	err := s.next.Blocks.Unmute(ctx, muterID, mutedID)
+	endSpan(span, err, rowsUnknown)
+
+	return err
+}
+
+type trashStore struct {
+	next store.Storage
+}
+
+func (s *trashStore) List(ctx context.Context, userID int64, retention time.Duration, tq store.TrashQuery) ([]*store.TrashItem, error) {
+	ctx, span := startSpan(ctx, "trash", "List", "SELECT")
+	items, err := s.next.Trash.List(ctx, userID, retention, tq)
+	endSpan(span, err, len(items))
+
+	return items, err
+}
+
+func (s *trashStore) Purge(ctx context.Context, retention time.Duration) (int64, int64, error) {
+	ctx, span := startSpan(ctx, "trash", "Purge", "DELETE")
+	posts, comments, err := s.next.Trash.Purge(ctx, retention)
+	endSpan(span, err, int(posts+comments))
+
+	return posts, comments, err
+}
+
+type txStore struct {
+	next store.Storage
+}
+
+func (s *txStore) WithTx(ctx context.Context, fn func(store.Storage) error) error {
+	ctx, span := tracer.Start(ctx, "tx.WithTx", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	err := s.next.Tx.WithTx(ctx, func(tx store.Storage) error {
+		return fn(InstrumentStorage(tx))
+	})
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
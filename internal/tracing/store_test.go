@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store"
+	"github.com/Amir-Zouerami/EWG-simple-API-server/internal/store/memstore"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStoreSpanRows(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	s := InstrumentStorage(memstore.New())
+	ctx := context.Background()
+
+	user := &store.User{Username: "alice", Email: "alice@example.com"}
+
+	if err := s.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	post := &store.Post{Title: "title", Content: "content", UserID: user.ID}
+
+	if err := s.Posts.Create(ctx, post); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = s.Posts.GetByID(ctx, post.ID+1)
+
+	if err := s.Posts.DeleteByID(ctx, post.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// -1 stands for an unset attribute.
+	want := map[string]int64{
+		"users.Create":     1,
+		"posts.Create":     1,
+		"posts.GetByID":    0,
+		"posts.DeleteByID": -1,
+	}
+
+	spans := recorder.Ended()
+
+	if len(spans) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(spans), len(want))
+	}
+
+	for _, span := range spans {
+		rows := int64(-1)
+
+		for _, attr := range span.Attributes() {
+			if attr.Key == RowsKey {
+				rows = attr.Value.AsInt64()
+			}
+		}
+
+		if rows != want[span.Name()] {
+			t.Errorf("%s: got rows %d, want %d", span.Name(), rows, want[span.Name()])
+		}
+	}
+}